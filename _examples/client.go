@@ -3,15 +3,17 @@ package authz
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"sync"
 
 	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	structpb "google.golang.org/protobuf/types/known/structpb"
 
-	"github.com/ben-mays/spicegen/examples/permissions/document"
-	"github.com/ben-mays/spicegen/examples/permissions/organization"
-	"github.com/ben-mays/spicegen/examples/permissions/team"
+	"github.com/ben-mays/spicegen/_examples/permissions/document"
+	"github.com/ben-mays/spicegen/_examples/permissions/organization"
+	"github.com/ben-mays/spicegen/_examples/permissions/team"
 )
 
 // SpiceDBClient is the interface that the spicegen generated client wraps.
@@ -25,13 +27,150 @@ type Client struct {
 	sync.RWMutex
 
 	spicedbClient SpiceDBClient
+	// Added to every object type and caveat name sent to spicedb, i.e. document -> tenant1/document
+	objectPrefix string
 	// Lock protects lastZedToken. Updated whenever a write occurs to provide read-my-write semantics.
 	lastZedToken string
 }
 
-func NewClient(spicedbClient SpiceDBClient) SpiceGenClient {
-	return &Client{
+// DefaultObjectPrefix is the object prefix the client was generated with, see WithObjectPrefix.
+const DefaultObjectPrefix = ""
+
+// ClientOption configures the Client created by NewClient.
+type ClientOption func(*Client)
+
+// WithObjectPrefix sets the prefix added to every object type and caveat name sent to spicedb, i.e. to use the same
+// client for each tenant of a multi-tenant schema. An empty prefix sends the names as generated.
+func WithObjectPrefix(prefix string) ClientOption {
+	return func(c *Client) {
+		c.objectPrefix = prefix
+	}
+}
+
+func NewClient(spicedbClient SpiceDBClient, opts ...ClientOption) SpiceGenClient {
+	c := &Client{
 		spicedbClient: spicedbClient,
+		objectPrefix:  DefaultObjectPrefix,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Returns the name spicedb knows the object type or caveat by
+func (c *Client) prefixed(name string) string {
+	if c.objectPrefix == "" {
+		return name
+	}
+	return c.objectPrefix + "/" + name
+}
+
+var (
+	// ErrUnknownRelation is returned when the relation or permission does not exist on the resource type, or when
+	// writing or deleting a relationship for a permission.
+	ErrUnknownRelation = errors.New("unknown relation")
+	// ErrSubjectTypeNotAllowed is returned when the subject type is not allowed on the relation or permission.
+	ErrSubjectTypeNotAllowed = errors.New("subject type not allowed")
+	// ErrSubjectRelationNotAllowed is returned when the optional subject relation is not allowed on the relation.
+	ErrSubjectRelationNotAllowed = errors.New("subject relation not allowed")
+	// ErrCaveatRequired is returned when writing a relationship for a subject that is only allowed with a caveat.
+	ErrCaveatRequired = errors.New("caveat required")
+	// ErrCaveatNotAllowed is returned when writing a relationship with a caveat the relation does not allow for the subject.
+	ErrCaveatNotAllowed = errors.New("caveat not allowed")
+)
+
+type allowedSubject struct {
+	subjectType     ResourceType
+	subjectRelation string
+	wildcard        bool
+	caveat          string
+}
+
+type allowedRelation struct {
+	permission bool
+	// the subjects that can be written to a relation
+	subjects []allowedSubject
+	// the subject types that can hold the relation or permission, i.e. user for a relation of team#member
+	holders []ResourceType
+}
+
+// allowedSubjects holds the subjects allowed for every relation and permission in the schema, keyed by resource type
+// and relation name. Subject types are checked against it before any request is made.
+var allowedSubjects = map[ResourceType]map[string]allowedRelation{
+	Document: {
+		"docorg": {subjects: []allowedSubject{{subjectType: "organization"}}, holders: []ResourceType{"organization"}},
+		"reader": {subjects: []allowedSubject{{subjectType: "user"}}, holders: []ResourceType{"user"}},
+		"writer": {subjects: []allowedSubject{{subjectType: "user"}}, holders: []ResourceType{"user"}},
+		"view":   {permission: true, holders: []ResourceType{"user"}},
+	},
+	Organization: {
+		"administrator":      {subjects: []allowedSubject{{subjectType: "team"}, {subjectType: "user"}}, holders: []ResourceType{"team", "user"}},
+		"view_all_documents": {permission: true, holders: []ResourceType{"user"}},
+	},
+	Team: {
+		"member": {subjects: []allowedSubject{{subjectType: "team", subjectRelation: "member"}, {subjectType: "user"}}, holders: []ResourceType{"user"}},
+	},
+}
+
+// The request a subject is validated for
+type validation int
+
+const (
+	// CheckPermission, of a relation or permission
+	validateCheck validation = iota
+	// DeleteRelationship, of a relation whatever its caveat
+	validateDelete
+	// AddRelationship, of a relation with a caveat it allows for the subject
+	validateWrite
+)
+
+// validateSubject checks the subject against the allowed subjects table. Checks accept any subject type holding the
+// relation or permission, writes and deletes only the subjects of a relation, and the caveat is only checked for writes.
+func validateSubject(resourceType ResourceType, relation string, subject Resource, subjectRelation string, caveat *pb.ContextualizedCaveat, v validation) error {
+	rel, ok := allowedSubjects[resourceType][relation]
+	if !ok {
+		return fmt.Errorf("%w: %s#%s", ErrUnknownRelation, resourceType, relation)
+	}
+	if v == validateCheck {
+		for _, holder := range rel.holders {
+			if holder == subject.ResourceType() {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s on %s#%s", ErrSubjectTypeNotAllowed, subject.ResourceType(), resourceType, relation)
+	}
+	if rel.permission {
+		return fmt.Errorf("%w: %s#%s is a permission", ErrUnknownRelation, resourceType, relation)
+	}
+	subjectType := string(subject.ResourceType())
+	wildcard := subject.ID() == "*"
+	if wildcard {
+		subjectType += ":*"
+	}
+	typeAllowed, relationAllowed := false, false
+	for _, a := range rel.subjects {
+		if a.subjectType != subject.ResourceType() || a.wildcard != wildcard {
+			continue
+		}
+		typeAllowed = true
+		if a.subjectRelation != subjectRelation {
+			continue
+		}
+		relationAllowed = true
+		if v == validateDelete || (caveat == nil && a.caveat == "") || (caveat != nil && caveat.CaveatName == a.caveat) {
+			return nil
+		}
+	}
+	switch {
+	case !typeAllowed:
+		return fmt.Errorf("%w: %s on %s#%s", ErrSubjectTypeNotAllowed, subjectType, resourceType, relation)
+	case !relationAllowed:
+		return fmt.Errorf("%w: %s#%s on %s#%s", ErrSubjectRelationNotAllowed, subjectType, subjectRelation, resourceType, relation)
+	case caveat == nil:
+		return fmt.Errorf("%w: %s on %s#%s", ErrCaveatRequired, subjectType, resourceType, relation)
+	default:
+		return fmt.Errorf("%w: %s for %s on %s#%s", ErrCaveatNotAllowed, caveat.CaveatName, subjectType, resourceType, relation)
 	}
 }
 
@@ -44,6 +183,9 @@ func (c *Client) getConsistency() *pb.Consistency {
 }
 
 func (c *Client) CheckPermission(ctx context.Context, subject Resource, permission string, resource Resource, opts *CheckPermissionOptions) (bool, error) {
+	if err := validateSubject(resource.ResourceType(), permission, subject, "", nil, validateCheck); err != nil {
+		return false, err
+	}
 	c.RLock()
 	defer c.RUnlock()
 	var context *structpb.Struct
@@ -54,10 +196,10 @@ func (c *Client) CheckPermission(ctx context.Context, subject Resource, permissi
 		Consistency: c.getConsistency(),
 		Context:     context,
 		Subject: &pb.SubjectReference{
-			Object: &pb.ObjectReference{ObjectType: string(subject.ResourceType()), ObjectId: subject.ID()},
+			Object: &pb.ObjectReference{ObjectType: c.prefixed(string(subject.ResourceType())), ObjectId: subject.ID()},
 		},
 		Permission: permission,
-		Resource:   &pb.ObjectReference{ObjectType: string(resource.ResourceType()), ObjectId: resource.ID()},
+		Resource:   &pb.ObjectReference{ObjectType: c.prefixed(string(resource.ResourceType())), ObjectId: resource.ID()},
 	})
 	if err != nil {
 		return false, err
@@ -65,24 +207,32 @@ func (c *Client) CheckPermission(ctx context.Context, subject Resource, permissi
 	return resp.Permissionship == pb.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION, nil
 }
 
-func (c *Client) CheckOrganizationPermission(ctx context.Context, subject Resource, permission organization.OrganizationPermission, resource OrganizationResource, opts *CheckPermissionOptions) (bool, error) {
+func (c *Client) CheckDocumentPermission(ctx context.Context, subject UserResource, permission document.DocumentPermission, resource DocumentResource, opts *CheckPermissionOptions) (bool, error) {
 	return c.CheckPermission(ctx, subject, string(permission), resource, opts)
 }
 
-func (c *Client) CheckDocumentPermission(ctx context.Context, subject Resource, permission document.DocumentPermission, resource DocumentResource, opts *CheckPermissionOptions) (bool, error) {
+func (c *Client) CheckOrganizationPermission(ctx context.Context, subject UserResource, permission organization.OrganizationPermission, resource OrganizationResource, opts *CheckPermissionOptions) (bool, error) {
 	return c.CheckPermission(ctx, subject, string(permission), resource, opts)
 }
 
 func (c *Client) AddRelationship(ctx context.Context, resource Resource, relation string, subject Resource, opts *AddRelationshipOptions) error {
-	c.Lock()
-	defer c.Unlock()
 	var caveat *pb.ContextualizedCaveat
+	subjectRelation := ""
 	if opts != nil {
 		caveat = opts.Caveat
+		subjectRelation = opts.OptionalSubjectRelation
+	}
+	if err := validateSubject(resource.ResourceType(), relation, subject, subjectRelation, caveat, validateWrite); err != nil {
+		return err
 	}
+	if caveat != nil && c.objectPrefix != "" {
+		caveat = &pb.ContextualizedCaveat{CaveatName: c.prefixed(caveat.CaveatName), Context: caveat.Context}
+	}
+	c.Lock()
+	defer c.Unlock()
 	subjectRef := &pb.SubjectReference{
 		Object: &pb.ObjectReference{
-			ObjectType: string(subject.ResourceType()),
+			ObjectType: c.prefixed(string(subject.ResourceType())),
 			ObjectId:   subject.ID(),
 		},
 	}
@@ -96,7 +246,7 @@ func (c *Client) AddRelationship(ctx context.Context, resource Resource, relatio
 				Subject:  subjectRef,
 				Relation: relation,
 				Resource: &pb.ObjectReference{
-					ObjectType: string(resource.ResourceType()),
+					ObjectType: c.prefixed(string(resource.ResourceType())),
 					ObjectId:   resource.ID(),
 				},
 				OptionalCaveat: caveat,
@@ -110,27 +260,59 @@ func (c *Client) AddRelationship(ctx context.Context, resource Resource, relatio
 	return nil
 }
 
-func (c *Client) AddTeamRelationship(ctx context.Context, resource TeamResource, relation team.TeamRelation, subject Resource, opts *AddRelationshipOptions) error {
+func (c *Client) AddDocumentRelationship(ctx context.Context, resource DocumentResource, relation document.DocumentRelation, subject DocumentRelationSubject, opts *AddRelationshipOptions) error {
 	return c.AddRelationship(ctx, resource, string(relation), subject, opts)
 }
 
-func (c *Client) AddOrganizationRelationship(ctx context.Context, resource OrganizationResource, relation organization.OrganizationRelation, subject Resource, opts *AddRelationshipOptions) error {
+// AddDocumentDocorg adds the subject to document#docorg.
+func (c *Client) AddDocumentDocorg(ctx context.Context, resource DocumentResource, subject OrganizationResource, opts *AddRelationshipOptions) error {
+	return c.AddRelationship(ctx, resource, string(document.DocorgRelation), subject, opts)
+}
+
+// AddDocumentReader adds the subject to document#reader.
+func (c *Client) AddDocumentReader(ctx context.Context, resource DocumentResource, subject UserResource, opts *AddRelationshipOptions) error {
+	return c.AddRelationship(ctx, resource, string(document.ReaderRelation), subject, opts)
+}
+
+// AddDocumentWriter adds the subject to document#writer.
+func (c *Client) AddDocumentWriter(ctx context.Context, resource DocumentResource, subject UserResource, opts *AddRelationshipOptions) error {
+	return c.AddRelationship(ctx, resource, string(document.WriterRelation), subject, opts)
+}
+
+func (c *Client) AddOrganizationRelationship(ctx context.Context, resource OrganizationResource, relation organization.OrganizationRelation, subject OrganizationRelationSubject, opts *AddRelationshipOptions) error {
 	return c.AddRelationship(ctx, resource, string(relation), subject, opts)
 }
 
-func (c *Client) AddDocumentRelationship(ctx context.Context, resource DocumentResource, relation document.DocumentRelation, subject Resource, opts *AddRelationshipOptions) error {
+// AddOrganizationAdministrator adds the subject to organization#administrator.
+func (c *Client) AddOrganizationAdministrator(ctx context.Context, resource OrganizationResource, subject OrganizationAdministratorSubject, opts *AddRelationshipOptions) error {
+	return c.AddRelationship(ctx, resource, string(organization.AdministratorRelation), subject, opts)
+}
+
+func (c *Client) AddTeamRelationship(ctx context.Context, resource TeamResource, relation team.TeamRelation, subject TeamRelationSubject, opts *AddRelationshipOptions) error {
 	return c.AddRelationship(ctx, resource, string(relation), subject, opts)
 }
 
+// AddTeamMember adds the subject to team#member.
+func (c *Client) AddTeamMember(ctx context.Context, resource TeamResource, subject TeamMemberSubject, opts *AddRelationshipOptions) error {
+	return c.AddRelationship(ctx, resource, string(team.MemberRelation), subject, opts)
+}
+
 func (c *Client) DeleteRelationship(ctx context.Context, resource Resource, relation string, subject Resource, opts *DeleteRelationshipOptions) error {
+	subjectRelation := ""
+	if opts != nil {
+		subjectRelation = opts.OptionalSubjectRelation
+	}
+	if err := validateSubject(resource.ResourceType(), relation, subject, subjectRelation, nil, validateDelete); err != nil {
+		return err
+	}
 	c.Lock()
 	defer c.Unlock()
-	subjectFilter := &pb.SubjectFilter{SubjectType: string(subject.ResourceType()), OptionalSubjectId: subject.ID()}
+	subjectFilter := &pb.SubjectFilter{SubjectType: c.prefixed(string(subject.ResourceType())), OptionalSubjectId: subject.ID()}
 	if opts != nil && opts.OptionalSubjectRelation != "" {
 		subjectFilter.OptionalRelation = &pb.SubjectFilter_RelationFilter{Relation: opts.OptionalSubjectRelation}
 	}
 	resp, err := c.spicedbClient.DeleteRelationships(ctx, &pb.DeleteRelationshipsRequest{
-		RelationshipFilter: &pb.RelationshipFilter{ResourceType: string(resource.ResourceType()), OptionalResourceId: resource.ID(), OptionalRelation: relation, OptionalSubjectFilter: subjectFilter},
+		RelationshipFilter: &pb.RelationshipFilter{ResourceType: c.prefixed(string(resource.ResourceType())), OptionalResourceId: resource.ID(), OptionalRelation: relation, OptionalSubjectFilter: subjectFilter},
 	})
 	if err != nil {
 		return err
@@ -139,24 +321,49 @@ func (c *Client) DeleteRelationship(ctx context.Context, resource Resource, rela
 	return nil
 }
 
-func (c *Client) DeleteTeamRelationship(ctx context.Context, resource TeamResource, relation team.TeamRelation, subject Resource, opts *DeleteRelationshipOptions) error {
+func (c *Client) DeleteDocumentRelationship(ctx context.Context, resource DocumentResource, relation document.DocumentRelation, subject DocumentRelationSubject, opts *DeleteRelationshipOptions) error {
 	return c.DeleteRelationship(ctx, resource, string(relation), subject, opts)
 }
 
-func (c *Client) DeleteOrganizationRelationship(ctx context.Context, resource OrganizationResource, relation organization.OrganizationRelation, subject Resource, opts *DeleteRelationshipOptions) error {
+// DeleteDocumentDocorg removes the subject from document#docorg.
+func (c *Client) DeleteDocumentDocorg(ctx context.Context, resource DocumentResource, subject OrganizationResource, opts *DeleteRelationshipOptions) error {
+	return c.DeleteRelationship(ctx, resource, string(document.DocorgRelation), subject, opts)
+}
+
+// DeleteDocumentReader removes the subject from document#reader.
+func (c *Client) DeleteDocumentReader(ctx context.Context, resource DocumentResource, subject UserResource, opts *DeleteRelationshipOptions) error {
+	return c.DeleteRelationship(ctx, resource, string(document.ReaderRelation), subject, opts)
+}
+
+// DeleteDocumentWriter removes the subject from document#writer.
+func (c *Client) DeleteDocumentWriter(ctx context.Context, resource DocumentResource, subject UserResource, opts *DeleteRelationshipOptions) error {
+	return c.DeleteRelationship(ctx, resource, string(document.WriterRelation), subject, opts)
+}
+
+func (c *Client) DeleteOrganizationRelationship(ctx context.Context, resource OrganizationResource, relation organization.OrganizationRelation, subject OrganizationRelationSubject, opts *DeleteRelationshipOptions) error {
 	return c.DeleteRelationship(ctx, resource, string(relation), subject, opts)
 }
 
-func (c *Client) DeleteDocumentRelationship(ctx context.Context, resource DocumentResource, relation document.DocumentRelation, subject Resource, opts *DeleteRelationshipOptions) error {
+// DeleteOrganizationAdministrator removes the subject from organization#administrator.
+func (c *Client) DeleteOrganizationAdministrator(ctx context.Context, resource OrganizationResource, subject OrganizationAdministratorSubject, opts *DeleteRelationshipOptions) error {
+	return c.DeleteRelationship(ctx, resource, string(organization.AdministratorRelation), subject, opts)
+}
+
+func (c *Client) DeleteTeamRelationship(ctx context.Context, resource TeamResource, relation team.TeamRelation, subject TeamRelationSubject, opts *DeleteRelationshipOptions) error {
 	return c.DeleteRelationship(ctx, resource, string(relation), subject, opts)
 }
 
+// DeleteTeamMember removes the subject from team#member.
+func (c *Client) DeleteTeamMember(ctx context.Context, resource TeamResource, subject TeamMemberSubject, opts *DeleteRelationshipOptions) error {
+	return c.DeleteRelationship(ctx, resource, string(team.MemberRelation), subject, opts)
+}
+
 func (c *Client) LookupResources(ctx context.Context, resourceType ResourceType, subject Resource, permission string, opts *LookupResourcesOptions) ([]string, string, error) {
 	c.RLock()
 	defer c.RUnlock()
 	subjectRef := &pb.SubjectReference{
 		Object: &pb.ObjectReference{
-			ObjectType: string(subject.ResourceType()),
+			ObjectType: c.prefixed(string(subject.ResourceType())),
 			ObjectId:   subject.ID(),
 		},
 	}
@@ -165,7 +372,7 @@ func (c *Client) LookupResources(ctx context.Context, resourceType ResourceType,
 	}
 	req := &pb.LookupResourcesRequest{
 		Consistency:        c.getConsistency(),
-		ResourceObjectType: string(resourceType),
+		ResourceObjectType: c.prefixed(string(resourceType)),
 		Subject:            subjectRef,
 		Permission:         permission,
 	}
@@ -177,7 +384,7 @@ func (c *Client) LookupResources(ctx context.Context, resourceType ResourceType,
 	}
 	client, err := c.spicedbClient.LookupResources(ctx, &pb.LookupResourcesRequest{
 		Consistency:        c.getConsistency(),
-		ResourceObjectType: string(resourceType),
+		ResourceObjectType: c.prefixed(string(resourceType)),
 		Permission:         permission,
 		Subject:            subjectRef,
 	})
@@ -206,23 +413,25 @@ func (c *Client) LookupResources(ctx context.Context, resourceType ResourceType,
 	return resources, lastToken, nil
 }
 
-func (c *Client) LookupOrganizationResources(ctx context.Context, subject Resource, permission organization.OrganizationPermission, opts *LookupResourcesOptions) ([]string, string, error) {
-	return c.LookupResources(ctx, Organization, subject, string(permission), opts)
+func (c *Client) LookupDocumentResources(ctx context.Context, subject UserResource, permission document.DocumentPermission, opts *LookupResourcesOptions) ([]string, string, error) {
+	return c.LookupResources(ctx, Document, subject, string(permission), opts)
 }
 
-func (c *Client) LookupDocumentResources(ctx context.Context, subject Resource, permission document.DocumentPermission, opts *LookupResourcesOptions) ([]string, string, error) {
-	return c.LookupResources(ctx, Document, subject, string(permission), opts)
+func (c *Client) LookupOrganizationResources(ctx context.Context, subject UserResource, permission organization.OrganizationPermission, opts *LookupResourcesOptions) ([]string, string, error) {
+	return c.LookupResources(ctx, Organization, subject, string(permission), opts)
 }
 
-func (c *Client) LookupSubjects(ctx context.Context, resource Resource, subjectType ResourceType, permission string, opts *LookupSubjectsOptions) ([]string, string, error) {
+func (c *Client) LookupSubjects(ctx context.Context, resource Resource, subjectType ResourceType, permission string, opts *LookupSubjectsOptions) ([]LookupSubjectsResult, string, error) {
 	c.RLock()
 	defer c.RUnlock()
 	req := &pb.LookupSubjectsRequest{
-		Consistency:             c.getConsistency(),
-		Resource:                &pb.ObjectReference{ObjectType: string(resource.ResourceType()), ObjectId: resource.ID()},
-		SubjectObjectType:       string(subjectType),
-		OptionalSubjectRelation: opts.OptionalSubjectRelation,
-		Permission:              permission,
+		Consistency:       c.getConsistency(),
+		Resource:          &pb.ObjectReference{ObjectType: c.prefixed(string(resource.ResourceType())), ObjectId: resource.ID()},
+		SubjectObjectType: c.prefixed(string(subjectType)),
+		Permission:        permission,
+	}
+	if opts != nil && opts.OptionalSubjectRelation != "" {
+		req.OptionalSubjectRelation = opts.OptionalSubjectRelation
 	}
 	if opts != nil && opts.Pagination.Limit != 0 {
 		req.OptionalConcreteLimit = uint32(opts.Pagination.Limit)
@@ -234,12 +443,16 @@ func (c *Client) LookupSubjects(ctx context.Context, resource Resource, subjectT
 	if err != nil {
 		return nil, "", err
 	}
-	subjects := make([]string, 0)
+	subjects := make([]LookupSubjectsResult, 0)
 	lastToken := ""
 	for {
 		resp, err := client.Recv()
 		if resp != nil && resp.Subject != nil {
-			subjects = append(subjects, resp.Subject.SubjectObjectId)
+			subject := LookupSubjectsResult{SubjectID: resp.Subject.SubjectObjectId, Wildcard: resp.Subject.SubjectObjectId == "*"}
+			for _, excluded := range resp.ExcludedSubjects {
+				subject.ExcludedSubjectIDs = append(subject.ExcludedSubjectIDs, excluded.SubjectObjectId)
+			}
+			subjects = append(subjects, subject)
 			if resp.AfterResultCursor != nil {
 				lastToken = resp.AfterResultCursor.Token
 			}
@@ -255,12 +468,12 @@ func (c *Client) LookupSubjects(ctx context.Context, resource Resource, subjectT
 	return subjects, lastToken, nil
 }
 
-func (c *Client) LookupOrganizationSubjects(ctx context.Context, resourceID string, subjectType ResourceType, permission organization.OrganizationPermission, opts *LookupSubjectsOptions) ([]string, string, error) {
-	resource, _ := NewResource(Organization, resourceID)
+func (c *Client) LookupDocumentSubjects(ctx context.Context, resourceID string, subjectType ResourceType, permission document.DocumentPermission, opts *LookupSubjectsOptions) ([]LookupSubjectsResult, string, error) {
+	resource, _ := NewResource(Document, resourceID)
 	return c.LookupSubjects(ctx, resource, subjectType, string(permission), opts)
 }
 
-func (c *Client) LookupDocumentSubjects(ctx context.Context, resourceID string, subjectType ResourceType, permission document.DocumentPermission, opts *LookupSubjectsOptions) ([]string, string, error) {
-	resource, _ := NewResource(Document, resourceID)
+func (c *Client) LookupOrganizationSubjects(ctx context.Context, resourceID string, subjectType ResourceType, permission organization.OrganizationPermission, opts *LookupSubjectsOptions) ([]LookupSubjectsResult, string, error) {
+	resource, _ := NewResource(Organization, resourceID)
 	return c.LookupSubjects(ctx, resource, subjectType, string(permission), opts)
 }
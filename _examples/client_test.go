@@ -6,10 +6,8 @@ import (
 	"fmt"
 	"testing"
 
-	authz "github.com/ben-mays/spicegen/examples"
-	"github.com/ben-mays/spicegen/examples/permissions/document"
-	"github.com/ben-mays/spicegen/examples/permissions/organization"
-	"github.com/ben-mays/spicegen/examples/permissions/team"
+	authz "github.com/ben-mays/spicegen/_examples"
+	"github.com/ben-mays/spicegen/_examples/permissions/document"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 
@@ -97,9 +95,8 @@ func TestSpiceDB(t *testing.T) {
 	svc := authz.NewClient(spicedb)
 
 	// Add user:ben to organization:nike
-	err = svc.AddOrganizationRelationship(
+	err = svc.AddOrganizationAdministrator(
 		ctx, authz.NewOrganizationResource("nike"),
-		organization.AdministratorRelation,
 		authz.NewUserResource("ben"), nil)
 	assert.Nil(t, err)
 
@@ -141,6 +138,6 @@ func TestSpiceDB(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, 0, len(resources))
 
-	err = svc.AddTeamRelationship(ctx, authz.NewTeamResource("nike"), team.MemberRelation, authz.NewTeamResource("ben"), &authz.AddRelationshipOptions{OptionalSubjectRelation: "member"})
+	err = svc.AddTeamMember(ctx, authz.NewTeamResource("nike"), authz.NewTeamResource("ben"), &authz.AddRelationshipOptions{OptionalSubjectRelation: "member"})
 	assert.Nil(t, err)
 }
@@ -4,13 +4,27 @@ package document
 type DocumentPermission string
 
 const (
+	// ViewPermission is the document view permission.
+	//
+	// view indicates whether the user can view the document
+	//
+	//	view = reader + writer + docorg->view_all_documents
 	ViewPermission DocumentPermission = "view"
 )
 
 type DocumentRelation string
 
 const (
+	// DocorgRelation is the document docorg relation.
+	//
+	// docorg indicates that the organization owns this document
 	DocorgRelation DocumentRelation = "docorg"
+	// ReaderRelation is the document reader relation.
+	//
+	// reader indicates that the user is a reader on the document
 	ReaderRelation DocumentRelation = "reader"
+	// WriterRelation is the document writer relation.
+	//
+	// writer indicates that the user is a writer on the document
 	WriterRelation DocumentRelation = "writer"
 )
@@ -4,11 +4,19 @@ package organization
 type OrganizationPermission string
 
 const (
+	// ViewAllDocumentsPermission is the organization view_all_documents permission.
+	//
+	// view_all_documents indicates whether a user can view all documents in the org
+	//
+	//	view_all_documents = administrator
 	ViewAllDocumentsPermission OrganizationPermission = "view_all_documents"
 )
 
 type OrganizationRelation string
 
 const (
+	// AdministratorRelation is the organization administrator relation.
+	//
+	// administrator indicates that the user is an admin of the org
 	AdministratorRelation OrganizationRelation = "administrator"
 )
@@ -4,22 +4,21 @@ package authz
 import (
 	"context"
 	"errors"
+
 	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/ben-mays/spicegen/_examples/permissions/document"
+	"github.com/ben-mays/spicegen/_examples/permissions/organization"
+	"github.com/ben-mays/spicegen/_examples/permissions/team"
 	structpb "google.golang.org/protobuf/types/known/structpb"
-
-	"github.com/ben-mays/spicegen/examples/permissions/document"
-
-	"github.com/ben-mays/spicegen/examples/permissions/organization"
-	"github.com/ben-mays/spicegen/examples/permissions/team"
 )
 
 type ResourceType string
 
 const (
 	Document     ResourceType = "document"
-	User         ResourceType = "user"
-	Team         ResourceType = "team"
 	Organization ResourceType = "organization"
+	Team         ResourceType = "team"
+	User         ResourceType = "user"
 )
 
 type Resource interface {
@@ -33,19 +32,22 @@ func NewResource(resourceType ResourceType, ID string) (Resource, error) {
 	case Document:
 		return DocumentResource{rid: ID}, nil
 
-	case User:
-		return UserResource{rid: ID}, nil
+	case Organization:
+		return OrganizationResource{rid: ID}, nil
 
 	case Team:
 		return TeamResource{rid: ID}, nil
 
-	case Organization:
-		return OrganizationResource{rid: ID}, nil
+	case User:
+		return UserResource{rid: ID}, nil
 
 	}
 	return nil, errors.New("resourceType given is not valid")
 }
 
+// DocumentResource is an object of the document definition.
+//
+// document represents a document with access control
 type DocumentResource struct {
 	rid string
 }
@@ -62,20 +64,23 @@ func NewDocumentResource(ID string) DocumentResource {
 	return DocumentResource{rid: ID}
 }
 
-type UserResource struct {
+// OrganizationResource is an object of the organization definition.
+//
+// organization represents an organization that contains documents
+type OrganizationResource struct {
 	rid string
 }
 
-func (r UserResource) ID() string {
+func (r OrganizationResource) ID() string {
 	return r.rid
 }
 
-func (r UserResource) ResourceType() ResourceType {
-	return User
+func (r OrganizationResource) ResourceType() ResourceType {
+	return Organization
 }
 
-func NewUserResource(ID string) UserResource {
-	return UserResource{rid: ID}
+func NewOrganizationResource(ID string) OrganizationResource {
+	return OrganizationResource{rid: ID}
 }
 
 type TeamResource struct {
@@ -94,38 +99,102 @@ func NewTeamResource(ID string) TeamResource {
 	return TeamResource{rid: ID}
 }
 
-type OrganizationResource struct {
+// UserResource is an object of the user definition.
+//
+// user represents a user
+type UserResource struct {
 	rid string
 }
 
-func (r OrganizationResource) ID() string {
+func (r UserResource) ID() string {
 	return r.rid
 }
 
-func (r OrganizationResource) ResourceType() ResourceType {
-	return Organization
+func (r UserResource) ResourceType() ResourceType {
+	return User
 }
 
-func NewOrganizationResource(ID string) OrganizationResource {
-	return OrganizationResource{rid: ID}
+func NewUserResource(ID string) UserResource {
+	return UserResource{rid: ID}
 }
 
-type SpiceGenClient interface {
-	CheckDocumentPermission(ctx context.Context, subject Resource, permission document.DocumentPermission, resource DocumentResource, opts *CheckPermissionOptions) (bool, error)
-	CheckOrganizationPermission(ctx context.Context, subject Resource, permission organization.OrganizationPermission, resource OrganizationResource, opts *CheckPermissionOptions) (bool, error)
+// DocumentRelationSubject is a subject of the document relations. It is only implemented by OrganizationResource, UserResource.
+type DocumentRelationSubject interface {
+	Resource
+	isDocumentRelationSubject()
+}
+
+func (OrganizationResource) isDocumentRelationSubject() {}
+
+func (UserResource) isDocumentRelationSubject() {}
+
+// OrganizationRelationSubject is a subject of the organization relations. It is only implemented by TeamResource, UserResource.
+type OrganizationRelationSubject interface {
+	Resource
+	isOrganizationRelationSubject()
+}
 
-	AddDocumentRelationship(ctx context.Context, resource DocumentResource, relation document.DocumentRelation, subject Resource, opts *AddRelationshipOptions) error
-	AddTeamRelationship(ctx context.Context, resource TeamResource, relation team.TeamRelation, subject Resource, opts *AddRelationshipOptions) error
-	AddOrganizationRelationship(ctx context.Context, resource OrganizationResource, relation organization.OrganizationRelation, subject Resource, opts *AddRelationshipOptions) error
+func (TeamResource) isOrganizationRelationSubject() {}
 
-	DeleteDocumentRelationship(ctx context.Context, resource DocumentResource, relation document.DocumentRelation, subject Resource, opts *DeleteRelationshipOptions) error
-	DeleteTeamRelationship(ctx context.Context, resource TeamResource, relation team.TeamRelation, subject Resource, opts *DeleteRelationshipOptions) error
-	DeleteOrganizationRelationship(ctx context.Context, resource OrganizationResource, relation organization.OrganizationRelation, subject Resource, opts *DeleteRelationshipOptions) error
+func (UserResource) isOrganizationRelationSubject() {}
 
-	LookupDocumentResources(ctx context.Context, subject Resource, permission document.DocumentPermission, opts *LookupResourcesOptions) ([]string, string, error)
-	LookupDocumentSubjects(ctx context.Context, resourceID string, subjectType ResourceType, permission document.DocumentPermission, opts *LookupSubjectsOptions) ([]string, string, error)
-	LookupOrganizationResources(ctx context.Context, subject Resource, permission organization.OrganizationPermission, opts *LookupResourcesOptions) ([]string, string, error)
-	LookupOrganizationSubjects(ctx context.Context, resourceID string, subjectType ResourceType, permission organization.OrganizationPermission, opts *LookupSubjectsOptions) ([]string, string, error)
+// OrganizationAdministratorSubject is a subject of the organization administrator relation. It is only implemented by TeamResource, UserResource.
+type OrganizationAdministratorSubject interface {
+	Resource
+	isOrganizationAdministratorSubject()
+}
+
+func (TeamResource) isOrganizationAdministratorSubject() {}
+
+func (UserResource) isOrganizationAdministratorSubject() {}
+
+// TeamRelationSubject is a subject of the team relations. It is only implemented by TeamResource, UserResource.
+type TeamRelationSubject interface {
+	Resource
+	isTeamRelationSubject()
+}
+
+func (TeamResource) isTeamRelationSubject() {}
+
+func (UserResource) isTeamRelationSubject() {}
+
+// TeamMemberSubject is a subject of the team member relation. It is only implemented by TeamResource, UserResource.
+type TeamMemberSubject interface {
+	Resource
+	isTeamMemberSubject()
+}
+
+func (TeamResource) isTeamMemberSubject() {}
+
+func (UserResource) isTeamMemberSubject() {}
+
+type SpiceGenClient interface {
+	CheckDocumentPermission(ctx context.Context, subject UserResource, permission document.DocumentPermission, resource DocumentResource, opts *CheckPermissionOptions) (bool, error)
+	CheckOrganizationPermission(ctx context.Context, subject UserResource, permission organization.OrganizationPermission, resource OrganizationResource, opts *CheckPermissionOptions) (bool, error)
+
+	AddDocumentRelationship(ctx context.Context, resource DocumentResource, relation document.DocumentRelation, subject DocumentRelationSubject, opts *AddRelationshipOptions) error
+	AddOrganizationRelationship(ctx context.Context, resource OrganizationResource, relation organization.OrganizationRelation, subject OrganizationRelationSubject, opts *AddRelationshipOptions) error
+	AddTeamRelationship(ctx context.Context, resource TeamResource, relation team.TeamRelation, subject TeamRelationSubject, opts *AddRelationshipOptions) error
+
+	DeleteDocumentRelationship(ctx context.Context, resource DocumentResource, relation document.DocumentRelation, subject DocumentRelationSubject, opts *DeleteRelationshipOptions) error
+	DeleteOrganizationRelationship(ctx context.Context, resource OrganizationResource, relation organization.OrganizationRelation, subject OrganizationRelationSubject, opts *DeleteRelationshipOptions) error
+	DeleteTeamRelationship(ctx context.Context, resource TeamResource, relation team.TeamRelation, subject TeamRelationSubject, opts *DeleteRelationshipOptions) error
+
+	AddDocumentDocorg(ctx context.Context, resource DocumentResource, subject OrganizationResource, opts *AddRelationshipOptions) error
+	DeleteDocumentDocorg(ctx context.Context, resource DocumentResource, subject OrganizationResource, opts *DeleteRelationshipOptions) error
+	AddDocumentReader(ctx context.Context, resource DocumentResource, subject UserResource, opts *AddRelationshipOptions) error
+	DeleteDocumentReader(ctx context.Context, resource DocumentResource, subject UserResource, opts *DeleteRelationshipOptions) error
+	AddDocumentWriter(ctx context.Context, resource DocumentResource, subject UserResource, opts *AddRelationshipOptions) error
+	DeleteDocumentWriter(ctx context.Context, resource DocumentResource, subject UserResource, opts *DeleteRelationshipOptions) error
+	AddOrganizationAdministrator(ctx context.Context, resource OrganizationResource, subject OrganizationAdministratorSubject, opts *AddRelationshipOptions) error
+	DeleteOrganizationAdministrator(ctx context.Context, resource OrganizationResource, subject OrganizationAdministratorSubject, opts *DeleteRelationshipOptions) error
+	AddTeamMember(ctx context.Context, resource TeamResource, subject TeamMemberSubject, opts *AddRelationshipOptions) error
+	DeleteTeamMember(ctx context.Context, resource TeamResource, subject TeamMemberSubject, opts *DeleteRelationshipOptions) error
+
+	LookupDocumentResources(ctx context.Context, subject UserResource, permission document.DocumentPermission, opts *LookupResourcesOptions) ([]string, string, error)
+	LookupDocumentSubjects(ctx context.Context, resourceID string, subjectType ResourceType, permission document.DocumentPermission, opts *LookupSubjectsOptions) ([]LookupSubjectsResult, string, error)
+	LookupOrganizationResources(ctx context.Context, subject UserResource, permission organization.OrganizationPermission, opts *LookupResourcesOptions) ([]string, string, error)
+	LookupOrganizationSubjects(ctx context.Context, resourceID string, subjectType ResourceType, permission organization.OrganizationPermission, opts *LookupSubjectsOptions) ([]LookupSubjectsResult, string, error)
 }
 
 type CheckPermissionOptions struct {
@@ -152,6 +221,14 @@ type LookupSubjectsOptions struct {
 	OptionalSubjectRelation string
 }
 
+// LookupSubjectsResult is a subject found by LookupSubjects. A wildcard result (i.e. user:*) means every subject of
+// the type has the permission, except for the ExcludedSubjectIDs.
+type LookupSubjectsResult struct {
+	SubjectID          string
+	Wildcard           bool
+	ExcludedSubjectIDs []string
+}
+
 type Pagination struct {
 	Limit int
 	Token string
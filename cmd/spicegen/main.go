@@ -1,26 +1,49 @@
 package main
 
 import (
+	"context"
 	_ "embed"
-	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"path"
-	"sort"
 	"strings"
+	"time"
 
-	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
-	"github.com/ben-mays/spicegen/internal"
-	"golang.org/x/exp/maps"
+	"github.com/ben-mays/spicegen/gen"
 )
 
 func main() {
 	fs := flag.NewFlagSet("spicegen", flag.ContinueOnError)
-	schemaPath := fs.String(
+	schemaPaths := stringsFlag{}
+	fs.Var(
+		&schemaPaths,
 		"schema-file",
-		"schema.text",
-		"Optional. Path to schema file for generation. If none given, the tool will look for schema.text in the current directory.",
+		"Optional. Path to schema file for generation. May be a schema or zed validation (.yaml) file, a directory of .zed files or a glob, and may be repeated. If none given, the tool will look for schema.text in the current directory.",
+	)
+
+	schemaEndpoint := fs.String(
+		"schema-endpoint",
+		"",
+		"Optional. The grpc endpoint of a running spicedb, i.e. localhost:50051. If given, the schema is read from spicedb instead of a schema file.",
+	)
+
+	schemaToken := fs.String(
+		"schema-token",
+		"",
+		"Optional. The preshared key or bearer token for -schema-endpoint. Defaults to the SPICEDB_TOKEN environment variable.",
+	)
+
+	schemaInsecure := fs.Bool(
+		"schema-insecure",
+		false,
+		"Optional. If present, connects to -schema-endpoint without TLS.",
+	)
+
+	schemaCACert := fs.String(
+		"schema-ca-cert",
+		"",
+		"Optional. Path to a PEM encoded CA certificate to verify -schema-endpoint with. The system certificates are used if none given.",
 	)
 
 	outputPath := fs.String(
@@ -53,10 +76,30 @@ func main() {
 		"Optional. A prefix string to match against permission/relation names to ignore. Used to avoid exposing implicit permissions.",
 	)
 
+	objectPrefix := fs.String(
+		"object-prefix",
+		"",
+		"Optional. The object prefix of a multi-tenant schema, i.e. tenant1 for tenant1/document. It is stripped from the generated names and added back by the client at runtime.",
+	)
+
+	include := stringsFlag{}
+	fs.Var(
+		&include,
+		"include",
+		"Optional. A glob matching the definitions (i.e. billing/*) or relations and permissions (i.e. document#view*) to generate, may be repeated. If given, only the matching definitions, relations and permissions are generated, and definitions with a matching relation or permission.",
+	)
+
+	exclude := stringsFlag{}
+	fs.Var(
+		&exclude,
+		"exclude",
+		"Optional. A glob matching the definitions or relations and permissions not to generate, like -include, may be repeated. Excluded relations still take part in inferring subject types.",
+	)
+
 	outputImportPath := fs.String(
 		"import-path",
 		"",
-		"Required. The fully qualified module path for importing the generated client. e.x. github.com/ben-mays/spicegen/example",
+		"Optional. The fully qualified module path for importing the generated client. e.x. github.com/ben-mays/spicegen/example. This will default to the import path of the output directory in the enclosing go.mod if not given.",
 	)
 
 	skipClientGeneration := fs.Bool(
@@ -65,12 +108,71 @@ func main() {
 		"Optional. If present, will skip client generation and only generate types and permissions.",
 	)
 
+	emit := fs.String(
+		"emit",
+		"go",
+		fmt.Sprintf("Optional. A comma separated list of the emitters to run, from %s. go generates the client, docs a markdown reference in schema.md and graph a graphviz graph in schema.dot.", strings.Join(gen.Emitters(), ", ")),
+	)
+
+	layout := fs.String(
+		"layout",
+		gen.LayoutPackages,
+		"Optional. The layout of the generated enums. packages generates the enums of each definition in its own package under -enum-dir, flat generates them in the client package, prefixed with the definition, i.e. DocumentViewPermission.",
+	)
+
+	enumDir := fs.String(
+		"enum-dir",
+		"permissions",
+		"Optional. The directory of the enum packages in the packages layout, relative to the output path, i.e. internal/enums.",
+	)
+
+	templateDir := fs.String(
+		"template-dir",
+		"",
+		"Optional. A directory of templates overriding the built-in types.text, client.text and resource.text. Any other .text file in it generates a .go file of the same name in the output path.",
+	)
+
+	configPath := fs.String(
+		"config",
+		"",
+		"Optional. Path to a spicegen.yaml config file listing the targets to generate. If no schema flags are given, the tool will look for spicegen.yaml in the current directory.",
+	)
+
+	check := fs.Bool(
+		"check",
+		false,
+		"Optional. If present, compares the generated code with the files in the output path instead of writing them, listing stale files and exiting non-zero if any are out of date.",
+	)
+
+	diff := fs.Bool(
+		"diff",
+		false,
+		"Optional. Like -check, but also prints a unified diff of the stale files.",
+	)
+
+	verify := fs.Bool(
+		"verify",
+		false,
+		"Optional. If present, type checks the generated code before writing it, reporting errors with the template and schema element they come from. The imports of the generated code are resolved in the module of the output path.",
+	)
+
 	err := fs.Parse(os.Args[1:])
 	if err != nil {
 		fmt.Printf("Error parsing flags: %s", err.Error())
 		return
 	}
 
+	// A plain run uses the config file in the current directory, if there is one
+	if *configPath == "" && !targetFlagsSet(fs) {
+		if _, err := os.Stat(gen.ConfigFileName); err == nil {
+			*configPath = gen.ConfigFileName
+		}
+	}
+	if *configPath != "" && targetFlagsSet(fs) {
+		fmt.Println("Only -schema-token, -check, -diff and -verify can be used with a config file, set the other options on its targets.")
+		os.Exit(1)
+	}
+
 	wd, err := os.Getwd()
 	if err != nil {
 		err = fmt.Errorf("Error getting current directory: %s", err.Error())
@@ -82,123 +184,162 @@ func main() {
 		outputPath = &wd
 	}
 
-	// Setup output package name
-	if outputPackageName == nil || *outputPackageName == "" {
-		base := path.Base(*outputPath)
-		outputPackageName = &base
-	}
-
 	// if output path is relative, make it abs
 	if !path.IsAbs(*outputPath) {
 		newPath := path.Join(wd, *outputPath)
 		outputPath = &newPath
 	}
 
-	// Setup output file
+	// Setup output file, the client is written to the directory of a .go output path
 	outputFileName := "client.go"
-	if path.Dir(*outputPath) != *outputPath {
-		base := path.Base(*outputPath)
-		if path.Ext(*outputPath) == ".go" {
-			outputFileName = base
-		}
+	if path.Ext(*outputPath) == ".go" {
+		outputFileName = path.Base(*outputPath)
+		outputDir := path.Dir(*outputPath)
+		outputPath = &outputDir
 	}
 
-	if outputImportPath == nil || *outputImportPath == "" {
-		fmt.Println("Flag `input-path` is required.")
-		fs.Usage()
-		return
+	// Setup output package name
+	if outputPackageName == nil || *outputPackageName == "" {
+		base := path.Base(*outputPath)
+		outputPackageName = &base
 	}
 
-	generatedFilePath := path.Join(*outputPath, outputFileName)
-	permissionPath := path.Join(*outputPath, "permissions")
-	defer func() {
+	// Infer the import path from the enclosing go.mod if not set
+	if *configPath == "" && (outputImportPath == nil || *outputImportPath == "") {
+		importPath, err := gen.InferImportPath(*outputPath)
 		if err != nil {
-			fmt.Println(err)
-			fmt.Println("failed to generate client, cleaning up.")
-			os.Remove(generatedFilePath)
-			os.RemoveAll(permissionPath)
+			fmt.Printf("Flag `import-path` is required, it could not be inferred: %s\n", err.Error())
+			os.Exit(1)
 		}
-	}()
+		outputImportPath = &importPath
+	}
 
-	fmt.Printf("reading schema file %s\n", *schemaPath)
-	schematxt, err := os.ReadFile(*schemaPath)
-	if err != nil {
-		// try cwd
-		cwd, _ := os.Getwd()
-		schematxt, err = os.ReadFile(path.Join(cwd, *schemaPath))
+	if *schemaToken == "" {
+		*schemaToken = os.Getenv("SPICEDB_TOKEN")
+	}
+	if *configPath != "" {
+		targets, err := gen.ReadConfigFile(*configPath)
 		if err != nil {
-			err = fmt.Errorf("Error reading schema file: %s", err.Error())
-			return
+			fmt.Printf("Error reading config: %s\n", err.Error())
+			os.Exit(1)
+		}
+		upToDate := true
+		for _, target := range targets {
+			target.Config.RemoteSchemaOptions.Token = *schemaToken
+			target.Config.Verify = target.Config.Verify || *verify
+			ok, err := generate(target.Config, target.OutputPath, *check, *diff)
+			if err != nil {
+				fmt.Printf("Error generating target %s: %s\n", target.Name, err.Error())
+				os.Exit(1)
+			}
+			upToDate = upToDate && ok
+		}
+		if !upToDate {
+			fmt.Println("generated code is out of date, run spicegen to regenerate.")
+			os.Exit(1)
 		}
+		return
 	}
 
-	prefix := ""
-	resp, err := compiler.Compile(compiler.InputSchema{SchemaString: string(schematxt)}, compiler.ObjectTypePrefix(prefix))
+	if len(schemaPaths) == 0 && *schemaEndpoint == "" {
+		schemaPaths = stringsFlag{"schema.text"}
+	}
+	upToDate, err := generate(gen.Config{
+		SchemaFiles:    schemaPaths,
+		SchemaEndpoint: *schemaEndpoint,
+		RemoteSchemaOptions: gen.RemoteSchemaOptions{
+			Token:    *schemaToken,
+			Insecure: *schemaInsecure,
+			CACert:   *schemaCACert,
+		},
+		PackageName:    *outputPackageName,
+		ImportPath:     *outputImportPath,
+		ClientName:     *outputClientName,
+		InterfaceName:  *outputInterfaceName,
+		ClientFileName: outputFileName,
+		Emit:           strings.Split(*emit, ","),
+		SkipClient:     *skipClientGeneration,
+		Layout:         *layout,
+		EnumDir:        *enumDir,
+		TemplateDir:    *templateDir,
+		Verify:         *verify,
+		VerifyDir:      *outputPath,
+		ObjectPrefix:   *objectPrefix,
+		IgnorePrefix:   *ignorePrefix,
+		Include:        include,
+		Exclude:        exclude,
+	}, *outputPath, *check, *diff)
 	if err != nil {
-		err = fmt.Errorf("Error compiling schema file: %s", err.Error())
-		return
+		fmt.Printf("Error generating client: %s\n", err.Error())
+		os.Exit(1)
 	}
+	if !upToDate {
+		fmt.Println("generated code is out of date, run spicegen to regenerate.")
+		os.Exit(1)
+	}
+}
 
-	// create permission directories
-	err = os.Mkdir(permissionPath, 0755)
-	if err != nil && !errors.Is(err, os.ErrExist) {
-		err = fmt.Errorf("Error creating output directory: %s", err.Error())
-		return
-	} else {
-		// reset errExist errors to nil
-		err = nil
-	}
-	// generate client/resources
-	state := internal.BuildSchema(resp)
-	if ignorePrefix != nil && *ignorePrefix != "" {
-		// delete ignored keys from state to avoid rendering them
-		for _, resource := range state.Resources {
-			for key := range resource.Permissions {
-				if strings.HasPrefix(key, *ignorePrefix) {
-					delete(resource.Permissions, key)
-				}
-			}
-			for key := range resource.Relations {
-				if strings.HasPrefix(key, *ignorePrefix) {
-					delete(resource.Relations, key)
-				}
+// Generates the client into the output path. With check or diff, the generated files are compared with the output
+// path instead, returning whether they are up to date. Reading a remote schema and verifying the client time out
+// after 30 seconds.
+func generate(cfg gen.Config, outputPath string, check, diff bool) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	files, err := gen.Generate(ctx, cfg)
+	if err != nil {
+		return false, err
+	}
+	if check || diff {
+		diffs, err := gen.Diff(outputPath, files)
+		if err != nil {
+			return false, fmt.Errorf("error checking client: %w", err)
+		}
+		for _, d := range diffs {
+			if diff {
+				fmt.Print(d.Diff)
+			} else {
+				fmt.Printf("%s is out of date\n", path.Join(outputPath, d.Path))
 			}
 		}
+		return len(diffs) == 0, nil
 	}
-
-	// Sort everything
-	resources := SortedMap(state.Resources)
-	for i, rsc := range resources {
-		resources[i].PermissionsArray = SortedMap(rsc.Permissions)
-		resources[i].RelationsArray = SortedMap(rsc.Relations)
+	stale, err := gen.StaleFiles(outputPath, files)
+	if err != nil {
+		return false, fmt.Errorf("error writing client: %w", err)
 	}
-
-	fmt.Printf("writing types to %s with packageName %s\n", path.Join(*outputPath, "types.go"), *outputPackageName)
-	internal.GenTypes(maps.Values(state.Resources), *outputPath, "types.go", *outputPackageName, *outputInterfaceName, *outputImportPath)
-	if !*skipClientGeneration {
-		fmt.Printf("writing client to %s with packageName %s\n", path.Join(*outputPath, outputFileName), *outputPackageName)
-		internal.GenClient(maps.Values(state.Resources), *outputPath, outputFileName, *outputPackageName, *outputClientName, *outputInterfaceName, *outputImportPath)
+	for _, file := range files {
+		fmt.Printf("writing %s\n", path.Join(outputPath, file.Path))
 	}
-	for _, rsc := range resources {
-		internal.GenResource(rsc, permissionPath, rsc.Name)
+	for _, file := range stale {
+		fmt.Printf("removing %s\n", path.Join(outputPath, file))
 	}
+	if err := gen.WriteFiles(outputPath, files); err != nil {
+		return false, fmt.Errorf("error writing client: %w", err)
+	}
+	return true, nil
 }
 
-func SortedKeys[T any](anyMap map[string]T) []string {
-	keys := make([]string, 0)
-	for k := range anyMap {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-	return keys
+// Returns whether any flag configuring a single target was given, i.e. -schema-file or -import-path
+func targetFlagsSet(fs *flag.FlagSet) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "config", "schema-token", "check", "diff", "verify":
+		default:
+			set = true
+		}
+	})
+	return set
 }
 
-// Returns an array of values from a map, sorted by the keys
-func SortedMap[T any](anyMap map[string]T) []T {
-	keys := SortedKeys[T](anyMap)
-	values := make([]T, len(keys))
-	for i, k := range keys {
-		values[i] = anyMap[k]
-	}
-	return values
+// A flag that may be given more than once, i.e. -schema-file a.zed -schema-file b.zed
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
 }
@@ -0,0 +1,150 @@
+package gen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileName is the config file spicegen looks for in the current directory when run without schema flags
+const ConfigFileName = "spicegen.yaml"
+
+// Target is a client to generate, read from a config file
+type Target struct {
+	Name       string
+	OutputPath string // the directory the files are written to
+	Config     Config
+}
+
+// The spicegen.yaml format. Paths are relative to the directory of the config file.
+type configFile struct {
+	Targets []targetConfig `yaml:"targets"`
+}
+
+type targetConfig struct {
+	Name           string   `yaml:"name"`
+	Schema         []string `yaml:"schema"`
+	SchemaEndpoint string   `yaml:"schema_endpoint"`
+	SchemaInsecure bool     `yaml:"schema_insecure"`
+	SchemaCACert   string   `yaml:"schema_ca_cert"`
+	Output         string   `yaml:"output"`
+	Package        string   `yaml:"package"`
+	ImportPath     string   `yaml:"import_path"`
+	ClientName     string   `yaml:"client_name"`
+	InterfaceName  string   `yaml:"interface_name"`
+	ClientFile     string   `yaml:"client_file"`
+	SkipClient     bool     `yaml:"skip_client"`
+	Emit           []string `yaml:"emit"`
+	Layout         string   `yaml:"layout"`
+	EnumDir        string   `yaml:"enum_dir"`
+	TemplateDir    string   `yaml:"template_dir"`
+	Verify         bool     `yaml:"verify"`
+	VerifyDir      string   `yaml:"verify_dir"`
+	ObjectPrefix   string   `yaml:"object_prefix"`
+	IgnorePrefix   string   `yaml:"ignore_prefix"`
+	Include        []string `yaml:"include"`
+	Exclude        []string `yaml:"exclude"`
+}
+
+// ReadConfigFile reads the targets from a spicegen.yaml file. Relative paths are resolved against the directory of the
+// config file, the package name defaults to the name of the output directory, the import path to the one inferred from
+// the enclosing go.mod and the name of a target to its output.
+// Tokens for schema endpoints are not read from the file, set them on the RemoteSchemaOptions of each target.
+func ReadConfigFile(path string) ([]Target, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	var file configFile
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+	if len(file.Targets) == 0 {
+		return nil, fmt.Errorf("config file %s has no targets", path)
+	}
+	dir := filepath.Dir(path)
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	targets := make([]Target, 0, len(file.Targets))
+	names := map[string]bool{}
+	outputs := map[string]string{} // output directory to target name
+	for i, tc := range file.Targets {
+		if tc.Output == "" {
+			return nil, fmt.Errorf("target %d in %s: output is required", i+1, path)
+		}
+		name := tc.Name
+		if name == "" {
+			name = tc.Output
+		}
+		if names[name] {
+			return nil, fmt.Errorf("target %s is defined more than once in %s", name, path)
+		}
+		names[name] = true
+		// each output directory has one manifest, so targets writing to the same one would remove each other's files
+		output := filepath.Clean(resolve(tc.Output))
+		if other, ok := outputs[output]; ok {
+			return nil, fmt.Errorf("targets %s and %s both write to %s", other, name, output)
+		}
+		outputs[output] = name
+		schemaFiles := make([]string, len(tc.Schema))
+		for j, schema := range tc.Schema {
+			schemaFiles[j] = resolve(schema)
+		}
+		packageName := tc.Package
+		if packageName == "" {
+			packageName = filepath.Base(resolve(tc.Output))
+		}
+		verifyDir := resolve(tc.VerifyDir)
+		if verifyDir == "" {
+			verifyDir = output
+		}
+		importPath := tc.ImportPath
+		if importPath == "" {
+			inferred, err := InferImportPath(output)
+			if err != nil {
+				return nil, fmt.Errorf("target %s in %s: %w", name, path, err)
+			}
+			importPath = inferred
+		}
+		targets = append(targets, Target{
+			Name:       name,
+			OutputPath: output,
+			Config: Config{
+				SchemaFiles:    schemaFiles,
+				SchemaEndpoint: tc.SchemaEndpoint,
+				RemoteSchemaOptions: RemoteSchemaOptions{
+					Insecure: tc.SchemaInsecure,
+					CACert:   resolve(tc.SchemaCACert),
+				},
+				PackageName:    packageName,
+				ImportPath:     importPath,
+				ClientName:     tc.ClientName,
+				InterfaceName:  tc.InterfaceName,
+				ClientFileName: tc.ClientFile,
+				SkipClient:     tc.SkipClient,
+				Emit:           tc.Emit,
+				Layout:         tc.Layout,
+				EnumDir:        tc.EnumDir,
+				TemplateDir:    resolve(tc.TemplateDir),
+				Verify:         tc.Verify,
+				VerifyDir:      verifyDir,
+				ObjectPrefix:   tc.ObjectPrefix,
+				IgnorePrefix:   tc.IgnorePrefix,
+				Include:        tc.Include,
+				Exclude:        tc.Exclude,
+			},
+		})
+	}
+	return targets, nil
+}
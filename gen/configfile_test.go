@@ -0,0 +1,129 @@
+package gen
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadConfigFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		expected func(dir string) []Target
+		err      string
+	}{
+		{
+			name: "targets",
+			config: `targets:
+  - name: authz
+    schema: [schema/, /abs/shared.zed]
+    output: internal/authz
+    import_path: github.com/ben-mays/spicegen/internal/authz
+    client_name: AuthzClient
+    emit: [go, docs]
+    ignore_prefix: _
+    include: [document, "folder#view*"]
+    exclude: [document#internal]
+  - schema_endpoint: localhost:50051
+    schema_insecure: true
+    output: admin
+    package: adminz
+    import_path: github.com/ben-mays/spicegen/admin
+    skip_client: true
+    template_dir: templates
+    verify: true
+    verify_dir: .
+`,
+			expected: func(dir string) []Target {
+				return []Target{
+					{
+						Name:       "authz",
+						OutputPath: filepath.Join(dir, "internal/authz"),
+						Config: Config{
+							SchemaFiles:  []string{filepath.Join(dir, "schema"), "/abs/shared.zed"},
+							PackageName:  "authz",
+							ImportPath:   "github.com/ben-mays/spicegen/internal/authz",
+							ClientName:   "AuthzClient",
+							Emit:         []string{"go", "docs"},
+							IgnorePrefix: "_",
+							Include:      []string{"document", "folder#view*"},
+							Exclude:      []string{"document#internal"},
+							VerifyDir:    filepath.Join(dir, "internal/authz"),
+						},
+					},
+					{
+						Name:       "admin",
+						OutputPath: filepath.Join(dir, "admin"),
+						Config: Config{
+							SchemaFiles:         []string{},
+							SchemaEndpoint:      "localhost:50051",
+							RemoteSchemaOptions: RemoteSchemaOptions{Insecure: true},
+							PackageName:         "adminz",
+							ImportPath:          "github.com/ben-mays/spicegen/admin",
+							SkipClient:          true,
+							TemplateDir:         filepath.Join(dir, "templates"),
+							Verify:              true,
+							VerifyDir:           dir,
+						},
+					},
+				}
+			},
+		},
+		{
+			name:   "inferred import path",
+			config: "targets:\n  - schema: [schema/]\n    output: internal/authz\n",
+			expected: func(dir string) []Target {
+				return []Target{{
+					Name:       "internal/authz",
+					OutputPath: filepath.Join(dir, "internal/authz"),
+					Config: Config{
+						SchemaFiles: []string{filepath.Join(dir, "schema")},
+						PackageName: "authz",
+						ImportPath:  "github.com/ben-mays/app/internal/authz",
+						VerifyDir:   filepath.Join(dir, "internal/authz"),
+					},
+				}}
+			},
+		},
+		{
+			name:   "unknown option",
+			config: "targets:\n  - output: authz\n    import-path: github.com/ben-mays/spicegen/authz\n",
+			err:    "error parsing config file %s/spicegen.yaml: yaml: unmarshal errors:\n  line 3: field import-path not found in type gen.targetConfig",
+		},
+		{
+			name:   "missing output",
+			config: "targets:\n  - name: authz\n",
+			err:    "target 1 in %s/spicegen.yaml: output is required",
+		},
+		{
+			name:   "duplicate target",
+			config: "targets:\n  - output: authz\n  - name: authz\n    output: other\n",
+			err:    "target authz is defined more than once in %s/spicegen.yaml",
+		},
+		{
+			name:   "duplicate output",
+			config: "targets:\n  - name: api\n    output: authz\n  - name: worker\n    output: ./authz/\n",
+			err:    "targets api and worker both write to %s/authz",
+		},
+		{
+			name:   "no targets",
+			config: "",
+			err:    "config file %s/spicegen.yaml has no targets",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := writeSchemaFiles(t, map[string]string{ConfigFileName: tc.config, "go.mod": "module github.com/ben-mays/app\n"})
+			targets, err := ReadConfigFile(filepath.Join(dir, ConfigFileName))
+			if tc.err != "" {
+				assert.EqualError(t, err, strings.ReplaceAll(tc.err, "%s", dir))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected(dir), targets)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package gen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// GeneratedHeader starts every Go file generated by spicegen
+const GeneratedHeader = "// " + generatedMarker
+
+// Marks the first line of every file generated by spicegen, whatever the comment syntax of the file, i.e.
+// <!-- Code generated by spicegen. DO NOT EDIT. --> for the docs
+const generatedMarker = "Code generated by spicegen"
+
+// FileDiff is a generated file that differs from the file in the output directory
+type FileDiff struct {
+	Path string // relative to the output directory
+	Diff string // unified diff from the file in the output directory to the generated file
+}
+
+// Diff compares the generated files with the files in the output directory without writing anything. Files that are
+// missing or stale are returned, as well as the files WriteFiles would remove, see StaleFiles. An empty result means
+// the output is up to date.
+func Diff(outputDir string, files []GeneratedFile) ([]FileDiff, error) {
+	diffs := make([]FileDiff, 0)
+	for _, file := range files {
+		current, err := os.ReadFile(filepath.Join(outputDir, file.Path))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("error reading %s: %w", file.Path, err)
+		}
+		fromFile := "a/" + file.Path
+		if err != nil {
+			fromFile = "/dev/null"
+		}
+		if err == nil && bytes.Equal(current, file.Content) {
+			continue
+		}
+		diff, err := unifiedDiff(fromFile, "b/"+file.Path, string(current), string(file.Content))
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, FileDiff{Path: file.Path, Diff: diff})
+	}
+	stale, err := StaleFiles(outputDir, files)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range stale {
+		current, err := os.ReadFile(filepath.Join(outputDir, path))
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", path, err)
+		}
+		diff, err := unifiedDiff("a/"+path, "/dev/null", string(current), "")
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, FileDiff{Path: path, Diff: diff})
+	}
+	return diffs, nil
+}
+
+func unifiedDiff(fromFile, toFile, from, to string) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(from),
+		B:        difflib.SplitLines(to),
+		FromFile: fromFile,
+		ToFile:   toFile,
+		Context:  3,
+	})
+}
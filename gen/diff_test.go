@@ -0,0 +1,141 @@
+package gen
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	generate := func(schema, layout string) []GeneratedFile {
+		files, err := Generate(context.Background(), Config{Schema: schema, PackageName: "authz", ImportPath: "github.com/ben-mays/spicegen/example", Layout: layout})
+		assert.NoError(t, err)
+		return files
+	}
+	schema := "definition document {\n relation parent: document\n}"
+	withFolder := schema + "\ndefinition folder {\n relation parent: folder\n}"
+	tests := []struct {
+		name     string
+		written  string // the schema generated earlier, defaults to schema
+		schema   string
+		layout   string
+		setup    func(dir string)
+		expected map[string]string // path to the start of the diff
+	}{
+		{
+			name:     "up to date",
+			schema:   schema,
+			expected: map[string]string{},
+		},
+		{
+			name:   "missing output",
+			schema: schema,
+			setup: func(dir string) {
+				assert.NoError(t, os.RemoveAll(filepath.Join(dir, "permissions")))
+			},
+			expected: map[string]string{
+				"permissions/document/document.go": "--- /dev/null\n+++ b/permissions/document/document.go\n",
+			},
+		},
+		{
+			name:   "changed schema",
+			schema: "definition document {\n relation parent: document\n relation owner: document\n}",
+			expected: map[string]string{
+				"client.go":                        "--- a/client.go\n+++ b/client.go\n",
+				"types.go":                         "--- a/types.go\n+++ b/types.go\n",
+				"permissions/document/document.go": "--- a/permissions/document/document.go\n+++ b/permissions/document/document.go\n",
+			},
+		},
+		{
+			name:    "deleted definition",
+			written: withFolder,
+			schema:  schema,
+			setup: func(dir string) {
+				// hand written files in the permissions directory are left alone
+				assert.NoError(t, os.WriteFile(filepath.Join(dir, "permissions", "document", "helpers.go"), []byte("package document\n"), 0644))
+			},
+			expected: map[string]string{
+				"client.go":                    "--- a/client.go\n+++ b/client.go\n",
+				"types.go":                     "--- a/types.go\n+++ b/types.go\n",
+				"permissions/folder/folder.go": "--- a/permissions/folder/folder.go\n+++ /dev/null\n",
+			},
+		},
+		{
+			name:    "deleted definition in the flat layout",
+			written: withFolder,
+			schema:  schema,
+			layout:  LayoutFlat,
+			expected: map[string]string{
+				"client.go":       "--- a/client.go\n+++ b/client.go\n",
+				"types.go":        "--- a/types.go\n+++ b/types.go\n",
+				"folder_enums.go": "--- a/folder_enums.go\n+++ /dev/null\n",
+			},
+		},
+		{
+			name:   "generated file not in the manifest",
+			schema: schema,
+			setup: func(dir string) {
+				// i.e. written by another tool or another spicegen target
+				assert.NoError(t, os.WriteFile(filepath.Join(dir, "other.go"), []byte(GeneratedHeader+". DO NOT EDIT\npackage authz\n"), 0644))
+			},
+			expected: map[string]string{},
+		},
+		{
+			name:   "output without a manifest",
+			schema: schema,
+			setup: func(dir string) {
+				assert.NoError(t, os.Remove(filepath.Join(dir, ManifestFileName)))
+				assert.NoError(t, os.MkdirAll(filepath.Join(dir, "permissions", "folder"), 0755))
+				assert.NoError(t, os.WriteFile(filepath.Join(dir, "permissions", "folder", "folder.go"), []byte(GeneratedHeader+". DO NOT EDIT\npackage folder\n"), 0644))
+				assert.NoError(t, os.WriteFile(filepath.Join(dir, "folder_enums.go"), []byte(GeneratedHeader+". DO NOT EDIT\npackage authz\n"), 0644))
+			},
+			expected: map[string]string{
+				"folder_enums.go":              "--- a/folder_enums.go\n+++ /dev/null\n",
+				"permissions/folder/folder.go": "--- a/permissions/folder/folder.go\n+++ /dev/null\n",
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			written := tc.written
+			if written == "" {
+				written = schema
+			}
+			assert.NoError(t, WriteFiles(dir, generate(written, tc.layout)))
+			if tc.setup != nil {
+				tc.setup(dir)
+			}
+			diffs, err := Diff(dir, generate(tc.schema, tc.layout))
+			assert.NoError(t, err)
+			actual := map[string]string{}
+			for _, d := range diffs {
+				actual[d.Path] = d.Diff
+			}
+			assert.Len(t, actual, len(tc.expected))
+			for path, prefix := range tc.expected {
+				assert.Contains(t, actual, path)
+				assert.True(t, strings.HasPrefix(actual[path], prefix), actual[path])
+			}
+		})
+	}
+}
+
+// The checked-in example is regenerated with every change to the generated code, see the README
+func TestDiffExamples(t *testing.T) {
+	files, err := Generate(context.Background(), Config{
+		SchemaFiles: []string{"../_examples/schema.text"},
+		PackageName: "authz",
+		ImportPath:  "github.com/ben-mays/spicegen/_examples",
+	})
+	assert.NoError(t, err)
+	diffs, err := Diff("../_examples", files)
+	assert.NoError(t, err)
+	for _, diff := range diffs {
+		t.Errorf("_examples/%s is out of date:\n%s", diff.Path, diff.Diff)
+	}
+}
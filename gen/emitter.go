@@ -0,0 +1,97 @@
+package gen
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/exp/maps"
+)
+
+//go:embed docs.text
+var docstmptext string
+
+//go:embed graph.text
+var graphtmptext string
+
+// Emitter generates files from the resolved schema, i.e. the Go client or documentation. Emitters receive the schema
+// after subject type inference, renames and ignores, with the resources and caveats sorted by name.
+type Emitter interface {
+	Emit(schema Schema, cfg Config) ([]GeneratedFile, error)
+}
+
+// An emitter that also takes the context of Generate, i.e. the go emitter to cancel the verification of its output
+type contextEmitter interface {
+	Emitter
+	emitContext(ctx context.Context, schema Schema, cfg Config) ([]GeneratedFile, error)
+}
+
+// EmitterFunc adapts a function to an Emitter
+type EmitterFunc func(schema Schema, cfg Config) ([]GeneratedFile, error)
+
+func (f EmitterFunc) Emit(schema Schema, cfg Config) ([]GeneratedFile, error) {
+	return f(schema, cfg)
+}
+
+var (
+	emittersMu sync.RWMutex
+	emitters   = map[string]Emitter{
+		"go":    goEmitter{},
+		"docs":  EmitterFunc(emitDocs),
+		"graph": EmitterFunc(emitGraph),
+	}
+)
+
+// RegisterEmitter makes an emitter available to Config.Emit by name, i.e. a TypeScript emitter registered as ts is
+// run with Emit: []string{"go", "ts"}. It panics if an emitter is already registered with the name.
+func RegisterEmitter(name string, emitter Emitter) {
+	emittersMu.Lock()
+	defer emittersMu.Unlock()
+	if emitter == nil {
+		panic("gen: RegisterEmitter emitter is nil")
+	}
+	if _, ok := emitters[name]; ok {
+		panic("gen: RegisterEmitter called twice for emitter " + name)
+	}
+	emitters[name] = emitter
+}
+
+// Emitters returns the sorted names of the registered emitters
+func Emitters() []string {
+	emittersMu.RLock()
+	defer emittersMu.RUnlock()
+	names := maps.Keys(emitters)
+	sort.Strings(names)
+	return names
+}
+
+func lookupEmitter(name string) (Emitter, error) {
+	emittersMu.RLock()
+	emitter, ok := emitters[name]
+	emittersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown emitter %q, available emitters are %s", name, strings.Join(Emitters(), ", "))
+	}
+	return emitter, nil
+}
+
+// Generates a markdown reference of the definitions, relations and permissions in schema.md
+func emitDocs(schema Schema, cfg Config) ([]GeneratedFile, error) {
+	file, err := genSource(struct{ Schema Schema }{Schema: schema}, docstmptext, "schema.md")
+	if err != nil {
+		return nil, err
+	}
+	return []GeneratedFile{file}, nil
+}
+
+// Generates a graphviz graph of the subjects each relation and permission accepts in schema.dot
+func emitGraph(schema Schema, cfg Config) ([]GeneratedFile, error) {
+	file, err := genSource(struct{ Schema Schema }{Schema: schema}, graphtmptext, "schema.dot")
+	if err != nil {
+		return nil, err
+	}
+	return []GeneratedFile{file}, nil
+}
@@ -0,0 +1,108 @@
+package gen
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmitters(t *testing.T) {
+	RegisterEmitter("test-enums", EmitterFunc(func(schema Schema, cfg Config) ([]GeneratedFile, error) {
+		names := make([]string, len(schema.ResourcesArray))
+		for i, rsc := range schema.ResourcesArray {
+			names[i] = rsc.Name + ":" + strings.Join(relationNames(rsc.RelationsArray), ",")
+		}
+		return []GeneratedFile{{Path: "enums.ts", Content: []byte(strings.Join(names, "\n"))}}, nil
+	}))
+	RegisterEmitter("test-conflict", EmitterFunc(func(schema Schema, cfg Config) ([]GeneratedFile, error) {
+		return []GeneratedFile{{Path: "schema.md"}}, nil
+	}))
+	schema := `caveat ip_allowed(ip ipaddress, cidr string) {
+ ip.in_cidr(cidr)
+}
+definition user {}
+/** a team of users */
+definition team {
+ relation member: user | team#member
+}
+definition document {
+ /** //spicegen:ignore */
+ relation hidden: user
+ /** readers | viewers
+  of the document */
+ relation reader: user with ip_allowed | user:* | team#member
+ permission view = reader
+}`
+	tests := []struct {
+		name     string
+		emit     []string
+		expected map[string][]string // path to expected lines
+		err      string
+	}{
+		{
+			name: "docs and graph",
+			emit: []string{"docs", "graph"},
+			expected: map[string][]string{
+				"schema.md": {
+					"## team\n\na team of users\n",
+					"| `reader` | `team#member`, `user with ip_allowed`, `user:*` | readers \\| viewers of the document |",
+					"| `view` | `reader` | `user` |  |",
+					"| `ip_allowed` | `cidr string`, `ip ipaddress` |",
+				},
+				"schema.dot": {
+					`"document" -> "team" [label="reader (team#member)"]`,
+					`"document" -> "user" [label="view", style=dashed]`,
+					`"team" -> "user" [label="member"]`,
+				},
+			},
+		},
+		{
+			name: "custom emitter after go",
+			emit: []string{"go", "test-enums"},
+			expected: map[string][]string{
+				"client.go": {"func (c *Client) CheckDocumentPermission("},
+				"enums.ts":  {"document:reader\nteam:member\nuser:"},
+			},
+		},
+		{
+			name: "unknown emitter",
+			emit: []string{"go", "ts"},
+			err:  `unknown emitter "ts", available emitters are docs, go, graph, test-conflict, test-enums`,
+		},
+		{
+			name: "conflicting emitters",
+			emit: []string{"docs", "test-conflict"},
+			err:  "emitters docs and test-conflict both generate schema.md",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			files, err := Generate(context.Background(), Config{Schema: schema, PackageName: "authz", ImportPath: "github.com/ben-mays/spicegen/example", Emit: tc.emit})
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			actual := map[string]string{}
+			for _, file := range files {
+				actual[file.Path] = string(file.Content)
+			}
+			for path, lines := range tc.expected {
+				for _, line := range lines {
+					assert.Contains(t, actual[path], line)
+				}
+			}
+		})
+	}
+	assert.Panics(t, func() { RegisterEmitter("go", goEmitter{}) })
+}
+
+func relationNames(rels []Relation) []string {
+	names := make([]string, len(rels))
+	for i, rel := range rels {
+		names[i] = rel.Name
+	}
+	return names
+}
@@ -0,0 +1,80 @@
+package gen
+
+import (
+	"errors"
+	"fmt"
+
+	corev1 "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+)
+
+// Position is a position in a schema source. Line and Column start at 1, and are 0 when unknown.
+type Position struct {
+	Source string // the schema file or endpoint, empty if unknown
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	switch {
+	case p.Line == 0:
+		return p.Source
+	case p.Source == "":
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.Source, p.Line, p.Column)
+}
+
+// SchemaError is an error in the input schema, i.e. a compile error or an invalid metatag, at the position it occurs.
+// Use errors.As to get the underlying error, i.e. a MetatagError.
+type SchemaError struct {
+	Position Position
+	Err      error
+}
+
+func (e *SchemaError) Error() string {
+	if pos := e.Position.String(); pos != "" {
+		return fmt.Sprintf("%s: %s", pos, e.Err)
+	}
+	return e.Err.Error()
+}
+
+func (e *SchemaError) Unwrap() error {
+	return e.Err
+}
+
+// GenerateError is an error rendering a generated file, i.e. a template that produces invalid Go source.
+type GenerateError struct {
+	Path   string // the path of the generated file, relative to the output directory
+	Err    error
+	Source string // the unformatted source when formatting failed, for debugging templates
+}
+
+func (e *GenerateError) Error() string {
+	return fmt.Sprintf("error generating %s: %s", e.Path, e.Err)
+}
+
+func (e *GenerateError) Unwrap() error {
+	return e.Err
+}
+
+// Converts spicedb compiler errors into a SchemaError with the position of the error
+func compileError(err error) error {
+	var ewc compiler.ErrorWithContext
+	if !errors.As(err, &ewc) {
+		return err
+	}
+	pos := Position{Source: string(ewc.Source)}
+	if line, col, lerr := ewc.SourceRange.Start().LineAndColumn(); lerr == nil {
+		pos.Line, pos.Column = line+1, col+1
+	}
+	return &SchemaError{Position: pos, Err: errors.New(ewc.BaseMessage)}
+}
+
+// Returns the position of a definition or relation in its schema source
+func sourcePosition(source string, pos *corev1.SourcePosition) Position {
+	if pos == nil {
+		return Position{Source: source}
+	}
+	return Position{Source: source, Line: int(pos.ZeroIndexedLineNumber) + 1, Column: int(pos.ZeroIndexedColumnPosition) + 1}
+}
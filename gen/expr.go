@@ -0,0 +1,121 @@
+package gen
+
+import (
+	"strings"
+
+	corev1 "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+type ExprKind string
+
+const (
+	UnionExpr           ExprKind = "union"            // a + b
+	IntersectionExpr    ExprKind = "intersection"     // a & b
+	ExclusionExpr       ExprKind = "exclusion"        // a - b
+	ComputedUsersetExpr ExprKind = "computed_userset" // reader
+	ArrowExpr           ExprKind = "arrow"            // docorg->view_all_documents
+	NilExpr             ExprKind = "nil"
+	ThisExpr            ExprKind = "this" // _this, only found in legacy schemas
+)
+
+// Expr is a node in the expression tree of a permission. For example, view = reader - banned is an exclusion with the
+// computed usersets reader and banned as children.
+type Expr struct {
+	Kind ExprKind
+	// Operands of a union, intersection or exclusion, in schema order. The first operand of an exclusion is the base
+	// set and the rest are subtracted from it.
+	Children []*Expr
+	// The relation of a computed userset, or the relation walked to by an arrow, i.e. view_all_documents
+	Relation string
+	// The relation of the resource an arrow walks over, i.e. docorg
+	Tupleset string
+}
+
+// Returns the expression the way it is written in the schema, i.e. reader + writer + docorg->view_all_documents
+func (e *Expr) String() string {
+	switch e.Kind {
+	case ComputedUsersetExpr:
+		return e.Relation
+	case ArrowExpr:
+		return e.Tupleset + "->" + e.Relation
+	case NilExpr:
+		return "nil"
+	case ThisExpr:
+		return "_this"
+	}
+	op := map[ExprKind]string{UnionExpr: " + ", IntersectionExpr: " & ", ExclusionExpr: " - "}[e.Kind]
+	children := make([]string, len(e.Children))
+	for i, child := range e.Children {
+		children[i] = child.String()
+		if len(child.Children) > 0 {
+			children[i] = "(" + children[i] + ")"
+		}
+	}
+	return strings.Join(children, op)
+}
+
+// Returns the computed usersets and arrows in the expression, in schema order
+func (e *Expr) Leaves() []*Expr {
+	return e.leaves(false)
+}
+
+// Returns the computed usersets and arrows that can grant the permission. Operands subtracted by an exclusion are left
+// out, since they can only ever remove subjects.
+func (e *Expr) GrantingLeaves() []*Expr {
+	return e.leaves(true)
+}
+
+func (e *Expr) leaves(granting bool) []*Expr {
+	switch e.Kind {
+	case ComputedUsersetExpr, ArrowExpr:
+		return []*Expr{e}
+	case UnionExpr, IntersectionExpr, ExclusionExpr:
+		children := e.Children
+		if granting && e.Kind == ExclusionExpr && len(children) > 0 {
+			children = children[:1]
+		}
+		res := make([]*Expr, 0)
+		for _, child := range children {
+			res = append(res, child.leaves(granting)...)
+		}
+		return res
+	}
+	return nil
+}
+
+// Builds the expression tree for a userset rewrite
+func buildExpr(rewrite *corev1.UsersetRewrite) *Expr {
+	expr := &Expr{}
+	var node *corev1.SetOperation
+	switch {
+	case rewrite.GetUnion() != nil:
+		expr.Kind, node = UnionExpr, rewrite.GetUnion()
+	case rewrite.GetIntersection() != nil:
+		expr.Kind, node = IntersectionExpr, rewrite.GetIntersection()
+	case rewrite.GetExclusion() != nil:
+		expr.Kind, node = ExclusionExpr, rewrite.GetExclusion()
+	default:
+		return nil
+	}
+	for _, child := range node.GetChild() {
+		switch val := child.GetChildType().(type) {
+		case *corev1.SetOperation_Child_XThis:
+			expr.Children = append(expr.Children, &Expr{Kind: ThisExpr})
+		case *corev1.SetOperation_Child_XNil:
+			expr.Children = append(expr.Children, &Expr{Kind: NilExpr})
+		case *corev1.SetOperation_Child_ComputedUserset:
+			expr.Children = append(expr.Children, &Expr{Kind: ComputedUsersetExpr, Relation: val.ComputedUserset.Relation})
+		case *corev1.SetOperation_Child_TupleToUserset:
+			expr.Children = append(expr.Children, &Expr{Kind: ArrowExpr, Tupleset: val.TupleToUserset.Tupleset.Relation, Relation: val.TupleToUserset.ComputedUserset.Relation})
+		case *corev1.SetOperation_Child_UsersetRewrite:
+			if child := buildExpr(val.UsersetRewrite); child != nil {
+				expr.Children = append(expr.Children, child)
+			}
+		}
+	}
+	// spicedb wraps single operand permissions (i.e. view = reader) in a union
+	if expr.Kind == UnionExpr && len(expr.Children) == 1 {
+		return expr.Children[0]
+	}
+	return expr
+}
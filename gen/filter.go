@@ -0,0 +1,108 @@
+package gen
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Selects the definitions, relations and permissions to generate. Patterns are path.Match globs over either the
+// definition name, i.e. billing/*, or the definition and relation or permission name, i.e. document#view*.
+type filter struct {
+	Include []string // if any, only the matching elements are generated, and definitions with a matching relation
+	Exclude []string // the matching elements are not generated, even if included
+	// Permissions and relations with names starting with the prefix are not generated
+	IgnorePrefix string
+}
+
+// Marks the definitions, relations and permissions not selected by the filter as ignored. Like the ignore metatag,
+// they still take part in resolving subject types but are not generated. Patterns matching nothing are an error, as
+// they are most likely a typo.
+func (f filter) apply(state map[string]Resource) error {
+	include, exclude := newPatterns("include", f.Include), newPatterns("exclude", f.Exclude)
+	for _, p := range []patterns{include, exclude} {
+		if err := p.validate(); err != nil {
+			return err
+		}
+	}
+	names := make([]string, 0, len(state))
+	for name := range state {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		rsc := state[name]
+		included := len(f.Include) == 0 || include.match(rsc.Name)
+		excluded := exclude.match(rsc.Name)
+		// a definition is generated if any of its relations or permissions is included, with only those
+		relationIncluded := false
+		for _, rels := range []map[string]Relation{rsc.Relations, rsc.Permissions} {
+			for relName, rel := range rels {
+				element := rsc.Name + "#" + rel.Name
+				relIncluded := include.match(element) || included
+				relExcluded := exclude.match(element) || f.IgnorePrefix != "" && strings.HasPrefix(rel.Name, f.IgnorePrefix)
+				if !relIncluded || relExcluded {
+					rel.Ignored = true
+					rels[relName] = rel
+				}
+				relationIncluded = relationIncluded || relIncluded
+			}
+		}
+		if excluded || !included && !relationIncluded {
+			rsc.Ignored = true
+			state[name] = rsc
+		}
+	}
+	for _, p := range []patterns{include, exclude} {
+		if err := p.unmatched(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Glob patterns, tracking which of them matched anything
+type patterns struct {
+	kind    string
+	globs   []string
+	matched map[string]bool
+}
+
+func newPatterns(kind string, globs []string) patterns {
+	return patterns{kind: kind, globs: globs, matched: map[string]bool{}}
+}
+
+func (p patterns) validate() error {
+	for _, glob := range p.globs {
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("invalid %s pattern %q: %w", p.kind, glob, err)
+		}
+	}
+	return nil
+}
+
+// Returns whether any pattern matches the definition or definition#relation. Definition patterns only match
+// definitions, and relation patterns only relations and permissions.
+func (p patterns) match(element string) bool {
+	found := false
+	for _, glob := range p.globs {
+		if strings.Contains(glob, "#") != strings.Contains(element, "#") {
+			continue
+		}
+		if ok, _ := path.Match(glob, element); ok {
+			p.matched[glob] = true
+			found = true
+		}
+	}
+	return found
+}
+
+func (p patterns) unmatched() error {
+	for _, glob := range p.globs {
+		if !p.matched[glob] {
+			return fmt.Errorf("%s pattern %q matches no definition, relation or permission", p.kind, glob)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package gen
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter(t *testing.T) {
+	schematxt := `definition user {}
+definition team {
+ relation member: user | team#member
+}
+definition organization {
+ relation admin: team#member
+ permission manage = admin
+}
+definition document {
+ relation org: organization
+ relation reader: user
+ relation internal_flag: user
+ permission view = reader + org->manage
+ permission edit = org->manage
+}`
+	tests := []struct {
+		name     string
+		filter   filter
+		expected map[string][]string // definition to its generated relations and permissions
+		subjects map[string]string   // definition to its permission subject type
+		err      string
+	}{
+		{
+			name: "no filter",
+			expected: map[string][]string{
+				"user":         {},
+				"team":         {"member"},
+				"organization": {"admin", "manage"},
+				"document":     {"edit", "internal_flag", "org", "reader", "view"},
+			},
+		},
+		{
+			name:   "include definitions",
+			filter: filter{Include: []string{"doc*", "user"}},
+			expected: map[string][]string{
+				"user":     {},
+				"document": {"edit", "internal_flag", "org", "reader", "view"},
+			},
+			// inferred through organization#admin and team#member, which are not generated
+			subjects: map[string]string{"document": "user"},
+		},
+		{
+			name:   "include relations",
+			filter: filter{Include: []string{"document#view", "organization#*"}},
+			expected: map[string][]string{
+				"organization": {"admin", "manage"},
+				"document":     {"view"},
+			},
+			// user is not generated, so the subject can't be typed
+			subjects: map[string]string{"document": "resource"},
+		},
+		{
+			name:   "exclude",
+			filter: filter{Exclude: []string{"team", "document#edit"}, IgnorePrefix: "internal_"},
+			expected: map[string][]string{
+				"user":         {},
+				"organization": {"admin", "manage"},
+				"document":     {"org", "reader", "view"},
+			},
+			subjects: map[string]string{"organization": "user", "document": "user"},
+		},
+		{
+			name:   "exclude wins over include",
+			filter: filter{Include: []string{"document", "user"}, Exclude: []string{"document#*"}},
+			expected: map[string][]string{
+				"user":     {},
+				"document": {},
+			},
+		},
+		{
+			name:   "unmatched pattern",
+			filter: filter{Include: []string{"document"}, Exclude: []string{"documents#view"}},
+			err:    `exclude pattern "documents#view" matches no definition, relation or permission`,
+		},
+		{
+			name:   "definition pattern does not match relations",
+			filter: filter{Exclude: []string{"view"}},
+			err:    `exclude pattern "view" matches no definition, relation or permission`,
+		},
+		{
+			name:   "invalid pattern",
+			filter: filter{Include: []string{"doc[ument"}},
+			err:    `invalid include pattern "doc[ument": syntax error in pattern`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			compiledSchema, err := compiler.Compile(compiler.InputSchema{SchemaString: schematxt}, compiler.ObjectTypePrefix(""))
+			assert.NoError(t, err)
+			schema, err := buildSchema(compiledSchema, tc.filter)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			actual := map[string][]string{}
+			for name, rsc := range schema.Resources {
+				actual[name] = append(relationNames(sortedMap(rsc.Relations)), relationNames(sortedMap(rsc.Permissions))...)
+				sort.Strings(actual[name])
+			}
+			assert.Equal(t, tc.expected, actual)
+			for name, subjectType := range tc.subjects {
+				assert.Equal(t, subjectType, schema.Resources[name].PermissionSubjectType, name)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+// Package gen generates strongly typed spicedb clients from spicedb schemas. Generate runs the whole pipeline in
+// memory, returning the generated files, and WriteFiles writes them to the output directory.
+package gen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"golang.org/x/exp/maps"
+)
+
+// Config configures a generation run. Exactly one of SchemaFiles, Schema or SchemaEndpoint must be given.
+type Config struct {
+	// Schema or zed validation files, directories of .zed files or globs
+	SchemaFiles []string
+	// Schema text, i.e. read from stdin
+	Schema string
+	// The grpc endpoint of a running spicedb to read the schema from
+	SchemaEndpoint string
+	// Connection options for SchemaEndpoint
+	RemoteSchemaOptions RemoteSchemaOptions
+
+	// Required. The package name of the generated client
+	PackageName string
+	// Required. The fully qualified import path of the generated client, i.e. github.com/ben-mays/spicegen/example
+	ImportPath string
+	// The name of the client impl, defaults to Client
+	ClientName string
+	// The name of the client interface, defaults to SpiceGenClient
+	InterfaceName string
+	// The file name of the client, defaults to client.go
+	ClientFileName string
+	// The emitters to run, in order, defaults to go. See Emitters for the registered emitters.
+	Emit []string
+	// Skips client generation, only generating types and permissions
+	SkipClient bool
+	// The layout of the generated enums, LayoutPackages (the default) or LayoutFlat
+	Layout string
+	// The directory of the enum packages in LayoutPackages, relative to the output directory. Defaults to permissions.
+	EnumDir string
+	// A directory of templates overriding types.text, client.text or resource.text. Any other .text file in it
+	// generates a file of the same name in the client package, i.e. wrappers.text generates wrappers.go.
+	TemplateDir string
+	// Type checks the generated Go code with go/types, reporting errors with the template and schema element they
+	// come from. The packages it imports are loaded with go list in VerifyDir, which must be in a module requiring
+	// them.
+	Verify bool
+	// The directory go list runs in for Verify, defaults to the current directory. The CLI and config file targets
+	// default it to the output directory. A directory that does not exist yet is resolved to its closest parent.
+	VerifyDir string
+
+	// The object prefix of a multi-tenant schema, stripped from the generated names and added by the client at runtime
+	ObjectPrefix string
+	// Permissions and relations with names starting with the prefix are not generated
+	IgnorePrefix string
+	// Glob patterns (see path.Match) over definitions, i.e. billing/*, and their relations and permissions, i.e.
+	// document#view*, matched after the object prefix is stripped. If any are given, only the matching elements are
+	// generated, and definitions with a matching relation or permission.
+	Include []string
+	// Glob patterns like Include for the elements not to generate, even if included. Like ignored elements, excluded
+	// ones still take part in inferring subject types.
+	Exclude []string
+}
+
+const (
+	// LayoutPackages generates the enums of each definition in their own package, i.e. permissions/document
+	LayoutPackages = "packages"
+	// LayoutFlat generates the enums in the client package, prefixed with the definition, i.e. DocumentViewPermission
+	LayoutFlat = "flat"
+)
+
+// GeneratedFile is a file generated by an emitter
+type GeneratedFile struct {
+	Path    string // relative to the output directory, i.e. permissions/document/document.go
+	Content []byte
+}
+
+func (cfg Config) withDefaults() Config {
+	if cfg.ClientName == "" {
+		cfg.ClientName = "Client"
+	}
+	if cfg.InterfaceName == "" {
+		cfg.InterfaceName = "SpiceGenClient"
+	}
+	if cfg.ClientFileName == "" {
+		cfg.ClientFileName = "client.go"
+	}
+	if cfg.Layout == "" {
+		cfg.Layout = LayoutPackages
+	}
+	if cfg.EnumDir == "" {
+		cfg.EnumDir = "permissions"
+	}
+	if len(cfg.Emit) == 0 {
+		cfg.Emit = []string{"go"}
+	}
+	return cfg
+}
+
+// Generate compiles the schema and runs the configured emitters, by default generating the client, types and
+// permissions packages. Nothing is written to disk.
+// Errors in the schema are returned as a *SchemaError with the position of the error.
+func Generate(ctx context.Context, cfg Config) ([]GeneratedFile, error) {
+	cfg = cfg.withDefaults()
+	if cfg.PackageName == "" {
+		return nil, errors.New("package name is required")
+	}
+	if cfg.ImportPath == "" {
+		return nil, errors.New("import path is required")
+	}
+	compiled, sources, err := compileInput(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ObjectPrefix != "" {
+		if err := StripObjectPrefix(compiled, cfg.ObjectPrefix); err != nil {
+			return nil, err
+		}
+	}
+	schema, err := buildSchema(compiled, filter{Include: cfg.Include, Exclude: cfg.Exclude, IgnorePrefix: cfg.IgnorePrefix})
+	if err != nil {
+		// BuildSchema only knows the position within the source, find the source from the definition
+		var schemaErr *SchemaError
+		var metatagErr MetatagError
+		if errors.As(err, &schemaErr) && errors.As(err, &metatagErr) && schemaErr.Position.Source == "" {
+			schemaErr.Position.Source = sources[metatagErr.Definition]
+			if cfg.ObjectPrefix != "" && schemaErr.Position.Source == "" {
+				schemaErr.Position.Source = sources[cfg.ObjectPrefix+"/"+metatagErr.Definition]
+			}
+		}
+		return nil, err
+	}
+	// Sort everything, the emitters only range over sorted arrays so the output is reproducible
+	schema.ResourcesArray = sortedMap(schema.Resources)
+	for i, rsc := range schema.ResourcesArray {
+		schema.ResourcesArray[i].PermissionsArray = sortedMap(rsc.Permissions)
+		schema.ResourcesArray[i].RelationsArray = sortedMap(rsc.Relations)
+		schema.Resources[rsc.Name] = schema.ResourcesArray[i]
+	}
+	schema.CaveatsArray = sortedMap(schema.Caveats)
+
+	files := make([]GeneratedFile, 0)
+	paths := map[string]string{}
+	for _, name := range cfg.Emit {
+		emitter, err := lookupEmitter(name)
+		if err != nil {
+			return nil, err
+		}
+		var emitted []GeneratedFile
+		if withContext, ok := emitter.(contextEmitter); ok {
+			emitted, err = withContext.emitContext(ctx, schema, cfg)
+		} else {
+			emitted, err = emitter.Emit(schema, cfg)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range emitted {
+			if other, ok := paths[file.Path]; ok {
+				return nil, fmt.Errorf("emitters %s and %s both generate %s", other, name, file.Path)
+			}
+			paths[file.Path] = name
+		}
+		files = append(files, emitted...)
+	}
+	return files, nil
+}
+
+// Compiles the configured schema input, returning the source each definition is defined in
+func compileInput(ctx context.Context, cfg Config) (*compiler.CompiledSchema, map[string]string, error) {
+	inputs := 0
+	for _, given := range []bool{len(cfg.SchemaFiles) > 0, cfg.Schema != "", cfg.SchemaEndpoint != ""} {
+		if given {
+			inputs++
+		}
+	}
+	if inputs != 1 {
+		return nil, nil, errors.New("exactly one of schema files, schema or schema endpoint is required")
+	}
+	switch {
+	case cfg.SchemaEndpoint != "":
+		client, conn, err := DialSchemaService(cfg.SchemaEndpoint, cfg.RemoteSchemaOptions)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer conn.Close()
+		compiled, err := CompileRemoteSchema(ctx, cfg.SchemaEndpoint, client)
+		return compiled, sourcesOf(compiled, cfg.SchemaEndpoint), err
+	case cfg.Schema != "":
+		compiled, err := compileSchema("schema", cfg.Schema)
+		return compiled, sourcesOf(compiled, "schema"), err
+	}
+	files, err := ResolveSchemaFiles(cfg.SchemaFiles)
+	if err != nil {
+		return nil, nil, err
+	}
+	return compileSchemaFiles(files)
+}
+
+// Returns the source of every definition in a schema compiled from a single source
+func sourcesOf(compiled *compiler.CompiledSchema, source string) map[string]string {
+	sources := map[string]string{}
+	if compiled != nil {
+		for _, def := range compiled.OrderedDefinitions {
+			sources[def.GetName()] = source
+		}
+	}
+	return sources
+}
+
+// Returns an array of values from a map, sorted by the keys
+func sortedMap[T any](anyMap map[string]T) []T {
+	keys := maps.Keys(anyMap)
+	sort.Strings(keys)
+	values := make([]T, len(keys))
+	for i, k := range keys {
+		values[i] = anyMap[k]
+	}
+	return values
+}
@@ -0,0 +1,788 @@
+package gen
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		cfg      Config
+		validate func(t *testing.T, files []GeneratedFile)
+		err      string
+	}{
+		{
+			name: "schema files",
+			files: map[string]string{
+				"user.zed":     "definition user {}",
+				"document.zed": "definition document {\n relation reader: user\n permission view = reader\n}",
+			},
+			cfg: Config{PackageName: "authz", ImportPath: "github.com/ben-mays/spicegen/example"},
+			validate: func(t *testing.T, files []GeneratedFile) {
+				paths := make([]string, len(files))
+				for i, file := range files {
+					paths[i] = file.Path
+				}
+				assert.Equal(t, []string{"types.go", "client.go", "permissions/document/document.go", "permissions/user/user.go"}, paths)
+				assert.Contains(t, string(files[0].Content), "package authz")
+				assert.Contains(t, string(files[1].Content), "func (c *Client) CheckDocumentPermission(")
+				assert.Contains(t, string(files[2].Content), `ViewPermission DocumentPermission = "view"`)
+			},
+		},
+		{
+			name:  "schema text without client",
+			files: map[string]string{},
+			cfg: Config{
+				Schema:         "definition user {}",
+				PackageName:    "authz",
+				ImportPath:     "github.com/ben-mays/spicegen/example",
+				SkipClient:     true,
+				ClientFileName: "spicedb.go",
+			},
+			validate: func(t *testing.T, files []GeneratedFile) {
+				assert.Len(t, files, 2)
+				assert.Equal(t, "types.go", files[0].Path)
+			},
+		},
+		{
+			name: "invalid metatags point at the schema file",
+			files: map[string]string{
+				"user.zed":     "definition user {}",
+				"document.zed": "definition document {\n /** //spicegen:renamed=viewer */\n relation reader: user\n}",
+			},
+			cfg: Config{PackageName: "authz", ImportPath: "github.com/ben-mays/spicegen/example"},
+			err: `%s/document.zed:3:2: invalid metatag "//spicegen:renamed=viewer" on document#reader: unknown metatag`,
+		},
+		{
+			name:  "missing import path",
+			files: map[string]string{"user.zed": "definition user {}"},
+			cfg:   Config{PackageName: "authz"},
+			err:   "import path is required",
+		},
+		{
+			name:  "several schema inputs",
+			files: map[string]string{"user.zed": "definition user {}"},
+			cfg:   Config{Schema: "definition user {}", PackageName: "authz", ImportPath: "github.com/ben-mays/spicegen/example"},
+			err:   "exactly one of schema files, schema or schema endpoint is required",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := writeSchemaFiles(t, tc.files)
+			if len(tc.files) > 0 {
+				tc.cfg.SchemaFiles = []string{filepath.Join(dir, "*.zed")}
+			}
+			files, err := Generate(context.Background(), tc.cfg)
+			if tc.err != "" {
+				assert.EqualError(t, err, strings.ReplaceAll(tc.err, "%s", dir))
+				return
+			}
+			assert.NoError(t, err)
+			tc.validate(t, files)
+		})
+	}
+}
+
+func TestGenerateSchemaError(t *testing.T) {
+	_, err := Generate(context.Background(), Config{
+		Schema:      "definition user {}\ndefinition document {\n relation reader: user |\n}",
+		PackageName: "authz",
+		ImportPath:  "github.com/ben-mays/spicegen/example",
+	})
+	var schemaErr *SchemaError
+	assert.True(t, errors.As(err, &schemaErr))
+	assert.Equal(t, Position{Source: "schema", Line: 4, Column: 1}, schemaErr.Position)
+}
+
+func TestGenerateDeterministic(t *testing.T) {
+	cfg := Config{
+		Schema: `definition user {}
+definition team {
+ relation member: user | team#member
+}
+caveat ip_allowed(ip ipaddress, cidr string, port int) {
+ ip.in_cidr(cidr)
+}
+definition folder {
+ relation viewer: user | team#member | user:*
+ relation editor: user | team#member
+ relation auditor: user with ip_allowed
+ permission view = viewer + editor + auditor
+ permission edit = editor - auditor
+}
+definition document {
+ relation parent: folder
+ relation writer: user | team
+ relation reader: user | user:*
+ permission write = writer + parent->edit
+ permission read = reader + write + parent->view
+}`,
+		PackageName: "authz",
+		ImportPath:  "github.com/ben-mays/spicegen/example",
+	}
+	expected, err := Generate(context.Background(), cfg)
+	assert.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		actual, err := Generate(context.Background(), cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+}
+
+func TestGenerateTemplateDir(t *testing.T) {
+	schema := "definition user {}\ndefinition team_policy {\n relation member: user\n permission view = member\n}"
+	tests := []struct {
+		name      string
+		templates map[string]string
+		expected  map[string]string // path to expected content
+		err       string
+	}{
+		{
+			name: "override resource template",
+			templates: map[string]string{
+				"resource.text": "package {{ .PackageName }}\n\nconst Definition = \"{{ .Resource.Name }}\"\n",
+				"README.md":     "not a template",
+			},
+			expected: map[string]string{
+				"permissions/team_policy/team_policy.go": "package team_policy\n\nconst Definition = \"team_policy\"\n",
+				"permissions/user/user.go":               "package user\n\nconst Definition = \"user\"\n",
+			},
+		},
+		{
+			name: "extra template with the schema and helpers",
+			templates: map[string]string{
+				"wrappers.text": `package {{ .PackageName }}
+
+var Definitions = []string{ {{ range $name, $rsc := .Schema.Resources }}"{{ ToSnake $rsc.GoName }}:{{ ToLowerCamel $name }}:{{ Plural $name }}",{{ end }} }
+`,
+			},
+			expected: map[string]string{
+				"wrappers.go": "package authz\n\nvar Definitions = []string{\"team_policy:teamPolicy:team_policies\", \"user:user:users\"}\n",
+			},
+		},
+		{
+			name:      "template error",
+			templates: map[string]string{"types.text": "package {{ .PackageName"},
+			err:       "error generating types.go: error parsing template: template: types.go:1: unclosed action",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			files, err := Generate(context.Background(), Config{
+				Schema:      schema,
+				PackageName: "authz",
+				ImportPath:  "github.com/ben-mays/spicegen/example",
+				TemplateDir: writeSchemaFiles(t, tc.templates),
+			})
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			actual := map[string]string{}
+			for _, file := range files {
+				actual[file.Path] = string(file.Content)
+			}
+			for path, content := range tc.expected {
+				assert.Equal(t, content, actual[path])
+			}
+		})
+	}
+}
+
+func TestPlural(t *testing.T) {
+	for word, expected := range map[string]string{
+		"user":     "users",
+		"policy":   "policies",
+		"day":      "days",
+		"box":      "boxes",
+		"branch":   "branches",
+		"status":   "statuses",
+		"Document": "Documents",
+		"API":      "APIS",
+		"":         "",
+	} {
+		assert.Equal(t, expected, plural(word), word)
+	}
+}
+
+func TestGenerateLayout(t *testing.T) {
+	schema := "definition user {}\ndefinition billing/invoice {\n relation payer: user\n permission pay = payer\n}"
+	tests := []struct {
+		name     string
+		cfg      Config
+		expected map[string][]string // path to expected lines
+		err      string
+	}{
+		{
+			name: "packages",
+			expected: map[string][]string{
+				"types.go":                               {`billing_invoice "github.com/ben-mays/spicegen/example/permissions/billing/invoice"`},
+				"client.go":                              {"permission billing_invoice.BillingInvoicePermission"},
+				"permissions/billing/invoice/invoice.go": {"package invoice", `PayPermission BillingInvoicePermission = "pay"`},
+				"permissions/user/user.go":               {"package user"},
+			},
+		},
+		{
+			name: "custom enum directory",
+			cfg:  Config{EnumDir: "internal/enums"},
+			expected: map[string][]string{
+				"types.go":  {`billing_invoice "github.com/ben-mays/spicegen/example/internal/enums/billing/invoice"`},
+				"client.go": {"permission billing_invoice.BillingInvoicePermission"},
+				"internal/enums/billing/invoice/invoice.go": {"package invoice"},
+				"internal/enums/user/user.go":               {"package user"},
+			},
+		},
+		{
+			name: "flat",
+			cfg:  Config{Layout: LayoutFlat},
+			expected: map[string][]string{
+				"types.go":  {"type BillingInvoiceResource struct"},
+				"client.go": {"permission BillingInvoicePermission"},
+				"billing_invoice_enums.go": {
+					"package authz",
+					`BillingInvoicePayPermission BillingInvoicePermission = "pay"`,
+					`BillingInvoicePayerRelation BillingInvoiceRelation = "payer"`,
+				},
+			},
+		},
+		{
+			name: "unknown layout",
+			cfg:  Config{Layout: "nested"},
+			err:  `unknown layout "nested", must be packages or flat`,
+		},
+		{
+			name: "enum directory outside the output",
+			cfg:  Config{EnumDir: "../enums"},
+			err:  `enum directory "../enums" must be a relative path within the output directory`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.cfg.Schema = schema
+			tc.cfg.PackageName = "authz"
+			tc.cfg.ImportPath = "github.com/ben-mays/spicegen/example"
+			files, err := Generate(context.Background(), tc.cfg)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			actual := map[string]string{}
+			for _, file := range files {
+				actual[file.Path] = string(file.Content)
+			}
+			assert.Len(t, actual, len(tc.expected))
+			for path, lines := range tc.expected {
+				for _, line := range lines {
+					assert.Contains(t, actual[path], line)
+				}
+			}
+			if tc.cfg.Layout == LayoutFlat {
+				assert.NotContains(t, actual["types.go"], "permissions")
+			}
+		})
+	}
+}
+
+func TestGenerateVerify(t *testing.T) {
+	tests := []struct {
+		name      string
+		schema    string
+		layout    string
+		include   []string
+		templates map[string]string
+		err       string
+	}{
+		{
+			name:    "filtered schema",
+			schema:  "definition user {}\ndefinition document {\n relation reader: user | user:*\n permission view = reader\n}",
+			include: []string{"document#view", "document#reader"},
+		},
+		{
+			name:   "permission only definition",
+			schema: "definition user {}\ndefinition flag {\n permission enabled = nil\n}",
+		},
+		{
+			name:   "permission only definition in the flat layout",
+			schema: "definition user {}\ndefinition flag {\n permission enabled = nil\n}",
+			layout: LayoutFlat,
+		},
+		{
+			name:   "type error in a resource template",
+			schema: "definition user {}\ndefinition document {\n relation reader: user\n}",
+			templates: map[string]string{
+				"resource.text": "package {{ .PackageName }}\n\ntype {{ .Resource.GoName }}Relation string\n{{ range .Resource.RelationsArray }}\nconst {{ ToCamel .Name }}Relation {{ $.Resource.GoName }}Relation = 1\n{{ end }}",
+			},
+			err: "the generated code does not compile:\n" +
+				"\tpermissions/document/document.go:5:41: cannot use 1 (untyped int constant) as DocumentRelation value in constant declaration (resource.text, relation document#reader)",
+		},
+		{
+			name:   "type error in an extra template",
+			schema: "definition user {}",
+			templates: map[string]string{
+				"wrappers.text": "package {{ .PackageName }}\n\nvar {{ range .Resources }}Default{{ .GoName }} = {{ .GoName }}Resourc{{ end }}\n",
+			},
+			err: "the generated code does not compile:\n" +
+				"\twrappers.go:3:19: undefined: UserResourc (wrappers.text)",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := Config{
+				Schema:      tc.schema,
+				PackageName: "authz",
+				ImportPath:  "github.com/ben-mays/spicegen/example",
+				Layout:      tc.layout,
+				Include:     tc.include,
+				Verify:      true,
+			}
+			if tc.templates != nil {
+				cfg.TemplateDir = writeSchemaFiles(t, tc.templates)
+			}
+			_, err := Generate(context.Background(), cfg)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestGenerateVerifyDir(t *testing.T) {
+	cfg := Config{
+		Schema:      "definition user {}\ndefinition document {\n relation reader: user\n}",
+		PackageName: "authz",
+		ImportPath:  "github.com/ben-mays/spicegen/example",
+		Verify:      true,
+	}
+	// a directory that does not exist yet, i.e. the output directory on the first run, is resolved to its parent
+	cfg.VerifyDir = filepath.Join("testdata", "authz", "permissions")
+	_, err := Generate(context.Background(), cfg)
+	assert.NoError(t, err)
+
+	// the imports can't be loaded outside of a module requiring them
+	cfg.VerifyDir = t.TempDir()
+	_, err = Generate(context.Background(), cfg)
+	assert.ErrorContains(t, err, "error loading the imports of the generated code")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cfg.VerifyDir = ""
+	_, err = Generate(ctx, cfg)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestGenerateCaveatWriters(t *testing.T) {
+	schema := `definition user {}
+caveat ip_allowlist(user_ip ipaddress, allowed_ranges list<string>) {
+ allowed_ranges.exists(r, user_ip.in_cidr(r))
+}
+caveat on_weekdays(today int, weekdays list<int>) {
+ today in weekdays
+}
+definition team {
+ relation member: user
+}
+definition document {
+ relation owner: user
+ relation reader: user with ip_allowlist | user:* with ip_allowlist | team#member with on_weekdays
+ relation viewer: user with ip_allowlist | team with ip_allowlist
+ relation public: user:* with on_weekdays
+}`
+	files, err := Generate(context.Background(), Config{
+		Schema:      schema,
+		PackageName: "authz",
+		ImportPath:  "github.com/ben-mays/spicegen/example",
+		Verify:      true,
+	})
+	assert.NoError(t, err)
+	client := string(files[1].Content)
+	for _, line := range []string{
+		"func (c *Client) AddDocumentPublicWithOnWeekdays(ctx context.Context, resource DocumentResource, subject Wildcard, caveat OnWeekdaysContext, opts *AddRelationshipOptions) error {",
+		"func (c *Client) AddDocumentReaderWithIpAllowlist(ctx context.Context, resource DocumentResource, subject Resource, caveat IpAllowlistContext, opts *AddRelationshipOptions) error {",
+		"func (c *Client) AddDocumentReaderWithOnWeekdays(ctx context.Context, resource DocumentResource, subject TeamResource, caveat OnWeekdaysContext, opts *AddRelationshipOptions) error {",
+		"func (c *Client) AddDocumentViewerWithIpAllowlist(ctx context.Context, resource DocumentResource, subject DocumentViewerSubject, caveat IpAllowlistContext, opts *AddRelationshipOptions) error {",
+		"// AddDocumentReaderWithOnWeekdays writes a document#reader relationship with the on_weekdays caveat, which the schema allows for team#member.\n// opts.Caveat is replaced by the caveat and the subject relation defaults to member.",
+		`withCaveat.OptionalSubjectRelation = "member"`,
+		"return c.AddRelationship(ctx, resource, string(document.ViewerRelation), subject, &withCaveat)",
+	} {
+		assert.Contains(t, client, line)
+	}
+	// only relations allowing a caveat get writers, and only for the caveats they allow
+	for _, name := range []string{"AddDocumentOwnerWith", "AddTeamMemberWith", "AddDocumentPublicWithIpAllowlist", "AddDocumentViewerWithOnWeekdays"} {
+		assert.NotContains(t, client, name)
+	}
+	assert.Contains(t, string(files[0].Content), "AddDocumentReaderWithIpAllowlist(ctx context.Context, resource DocumentResource, subject Resource, caveat IpAllowlistContext, opts *AddRelationshipOptions) error")
+
+	// today is left out of the written context, to be supplied at check time
+	runGenerated(t, schema, map[string]string{"fake_test.go": fakeSpiceDBSrc, "writer_test.go": `package authz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaveatWriter(t *testing.T) {
+	spicedb := &fakeSpiceDB{}
+	client := NewClient(spicedb)
+	err := client.AddDocumentReaderWithOnWeekdays(context.Background(), NewDocumentResource("doc"), NewTeamResource("eng"), OnWeekdaysContext{Weekdays: []int64{1, 2}}, nil)
+	assert.NoError(t, err)
+	assert.Len(t, spicedb.writes, 1)
+	rel := spicedb.writes[0].Updates[0].Relationship
+	assert.Equal(t, "member", rel.Subject.OptionalRelation)
+	assert.Equal(t, "on_weekdays", rel.OptionalCaveat.CaveatName)
+	assert.Equal(t, map[string]any{"weekdays": []any{float64(1), float64(2)}}, rel.OptionalCaveat.Context.AsMap())
+}
+`})
+}
+
+func TestGenerateRelationWriters(t *testing.T) {
+	schema := `definition user {}
+caveat on_weekdays(today int) {
+ today < 6
+}
+definition team {
+ relation member: user | team#member
+}
+definition organization {
+ relation admin: team#member
+}
+definition document {
+ relation org: organization
+ relation owner: user | team
+ relation reader: user | user:*
+ relation public: user:*
+ relation auditor: user with on_weekdays
+}`
+	files, err := Generate(context.Background(), Config{
+		Schema:      schema,
+		PackageName: "authz",
+		ImportPath:  "github.com/ben-mays/spicegen/example",
+		Verify:      true,
+	})
+	assert.NoError(t, err)
+	client := string(files[1].Content)
+	for _, line := range []string{
+		"func (c *Client) AddDocumentOrg(ctx context.Context, resource DocumentResource, subject OrganizationResource, opts *AddRelationshipOptions) error {",
+		"func (c *Client) AddDocumentOwner(ctx context.Context, resource DocumentResource, subject DocumentOwnerSubject, opts *AddRelationshipOptions) error {",
+		// wildcards are written with AddDocumentRelationshipPublic
+		"func (c *Client) AddDocumentReader(ctx context.Context, resource DocumentResource, subject UserResource, opts *AddRelationshipOptions) error {",
+		"func (c *Client) AddDocumentPublic(ctx context.Context, resource DocumentResource, subject Wildcard, opts *AddRelationshipOptions) error {",
+		"func (c *Client) DeleteDocumentAuditor(ctx context.Context, resource DocumentResource, subject UserResource, opts *DeleteRelationshipOptions) error {",
+		"func (c *Client) AddTeamMember(ctx context.Context, resource TeamResource, subject TeamMemberSubject, opts *AddRelationshipOptions) error {",
+		"// AddOrganizationAdmin adds the subject to organization#admin. The subject relation defaults to member.",
+		"func (c *Client) AddOrganizationAdmin(ctx context.Context, resource OrganizationResource, subject TeamResource, opts *AddRelationshipOptions) error {",
+		"func (c *Client) DeleteOrganizationAdmin(ctx context.Context, resource OrganizationResource, subject TeamResource, opts *DeleteRelationshipOptions) error {",
+		"func (TeamResource) isDocumentOwnerSubject() {}",
+	} {
+		assert.Contains(t, client+string(files[0].Content), line)
+	}
+	// the auditor relation requires a caveat, so it is only written by AddDocumentAuditorWithOnWeekdays
+	assert.NotContains(t, client, "AddDocumentAuditor(")
+	assert.Contains(t, string(files[0].Content), "AddDocumentOwner(ctx context.Context, resource DocumentResource, subject DocumentOwnerSubject, opts *AddRelationshipOptions) error")
+
+	runGenerated(t, schema, map[string]string{"fake_test.go": fakeSpiceDBSrc, "writer_test.go": `package authz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelationWriters(t *testing.T) {
+	ctx := context.Background()
+	spicedb := &fakeSpiceDB{}
+	client := NewClient(spicedb)
+	assert.NoError(t, client.AddOrganizationAdmin(ctx, NewOrganizationResource("acme"), NewTeamResource("eng"), nil))
+	assert.NoError(t, client.AddDocumentOwner(ctx, NewDocumentResource("doc"), NewTeamResource("eng"), nil))
+	assert.NoError(t, client.DeleteOrganizationAdmin(ctx, NewOrganizationResource("acme"), NewTeamResource("eng"), nil))
+	assert.Len(t, spicedb.writes, 2)
+	admin := spicedb.writes[0].Updates[0].Relationship
+	assert.Equal(t, "admin", admin.Relation)
+	assert.Equal(t, "member", admin.Subject.OptionalRelation)
+	owner := spicedb.writes[1].Updates[0].Relationship
+	assert.Equal(t, "owner", owner.Relation)
+	assert.Equal(t, "team", owner.Subject.Object.ObjectType)
+	assert.Empty(t, owner.Subject.OptionalRelation)
+	assert.Len(t, spicedb.deletes, 1)
+	assert.Equal(t, "member", spicedb.deletes[0].RelationshipFilter.OptionalSubjectFilter.OptionalRelation.Relation)
+}
+`})
+}
+
+func TestGenerateLookupSubjects(t *testing.T) {
+	schema := `definition user {}
+definition team {
+ relation member: user | team#member
+}
+definition document {
+ relation reader: user | user:* | team#member
+ permission view = reader
+}`
+	runGenerated(t, schema, map[string]string{"fake_test.go": fakeSpiceDBSrc, "lookup_test.go": `package authz
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupSubjects(t *testing.T) {
+	ctx := context.Background()
+	spicedb := &fakeSpiceDB{subjects: []*pb.LookupSubjectsResponse{
+		{Subject: &pb.ResolvedSubject{SubjectObjectId: "alice"}},
+		{Subject: &pb.ResolvedSubject{SubjectObjectId: "*"}, ExcludedSubjects: []*pb.ResolvedSubject{{SubjectObjectId: "bob"}}},
+	}}
+	client := NewClient(spicedb).(*Client)
+	// nil opts look up the subjects themselves
+	subjects, _, err := client.LookupSubjects(ctx, NewDocumentResource("doc"), User, "view", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []LookupSubjectsResult{{SubjectID: "alice"}, {SubjectID: "*", Wildcard: true, ExcludedSubjectIDs: []string{"bob"}}}, subjects)
+	assert.Empty(t, spicedb.lookups[0].OptionalSubjectRelation)
+
+	_, _, err = client.LookupSubjects(ctx, NewDocumentResource("doc"), Team, "view", &LookupSubjectsOptions{OptionalSubjectRelation: "member"})
+	assert.NoError(t, err)
+	assert.Equal(t, "member", spicedb.lookups[1].OptionalSubjectRelation)
+}
+`})
+}
+
+// Generates the client for the schema into a package under testdata and runs the test files, by name, against it
+// with go test
+func runGenerated(t *testing.T, schema string, testFiles map[string]string) {
+	if testing.Short() {
+		t.Skip("runs go test on the generated code")
+	}
+	assert.NoError(t, os.MkdirAll("testdata", 0o755))
+	dir, err := os.MkdirTemp("testdata", "run")
+	assert.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	files, err := Generate(context.Background(), Config{
+		Schema:      schema,
+		PackageName: "authz",
+		ImportPath:  "github.com/ben-mays/spicegen/gen/" + filepath.ToSlash(dir),
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, WriteFiles(dir, files))
+	for name, src := range testFiles {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644))
+	}
+	out, err := exec.Command("go", "test", "./"+filepath.ToSlash(dir)).CombinedOutput()
+	assert.NoError(t, err, string(out))
+}
+
+func TestGenerateCaveatContext(t *testing.T) {
+	schema := `definition user {}
+caveat expiry(now timestamp, expires_at timestamp, grace duration, max_uses int, note string, admin bool) {
+ now < expires_at + grace && max_uses > 0 && note != "" && !admin
+}
+definition document {
+ relation reader: user with expiry
+}`
+	runGenerated(t, schema, map[string]string{"caveat_test.go": `package authz
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpiryContext(t *testing.T) {
+	expiresAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	grace := time.Minute
+	maxUses := int64(0)
+	context, err := ExpiryContext{ExpiresAt: &expiresAt, Grace: &grace, MaxUses: &maxUses}.Struct()
+	assert.NoError(t, err)
+	// now, note and admin are left out to be supplied at check time, zero values that are set are not
+	assert.Equal(t, map[string]any{"expires_at": "2024-01-02T03:04:05Z", "grace": "1m0s", "max_uses": float64(0)}, context.AsMap())
+
+	context, err = ExpiryContext{}.Struct()
+	assert.NoError(t, err)
+	assert.Empty(t, context.AsMap())
+}
+`})
+}
+
+// A SpiceDBClient for the tests of the generated client, recording the requests it receives
+const fakeSpiceDBSrc = `package authz
+
+import (
+	"context"
+	"io"
+
+	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc"
+)
+
+type fakeSpiceDB struct {
+	pb.PermissionsServiceClient
+	pb.SchemaServiceClient
+	writes   []*pb.WriteRelationshipsRequest
+	deletes  []*pb.DeleteRelationshipsRequest
+	checks   []*pb.CheckPermissionRequest
+	lookups  []*pb.LookupSubjectsRequest
+	subjects []*pb.LookupSubjectsResponse // returned by LookupSubjects
+}
+
+func (f *fakeSpiceDB) requests() int {
+	return len(f.writes) + len(f.deletes) + len(f.checks) + len(f.lookups)
+}
+
+func (f *fakeSpiceDB) LookupSubjects(ctx context.Context, in *pb.LookupSubjectsRequest, opts ...grpc.CallOption) (pb.PermissionsService_LookupSubjectsClient, error) {
+	f.lookups = append(f.lookups, in)
+	return &fakeSubjectsStream{responses: f.subjects}, nil
+}
+
+type fakeSubjectsStream struct {
+	grpc.ClientStream
+	responses []*pb.LookupSubjectsResponse
+}
+
+func (s *fakeSubjectsStream) Recv() (*pb.LookupSubjectsResponse, error) {
+	if len(s.responses) == 0 {
+		return nil, io.EOF
+	}
+	resp := s.responses[0]
+	s.responses = s.responses[1:]
+	return resp, nil
+}
+
+func (f *fakeSpiceDB) WriteRelationships(ctx context.Context, in *pb.WriteRelationshipsRequest, opts ...grpc.CallOption) (*pb.WriteRelationshipsResponse, error) {
+	f.writes = append(f.writes, in)
+	return &pb.WriteRelationshipsResponse{WrittenAt: &pb.ZedToken{Token: "written"}}, nil
+}
+
+func (f *fakeSpiceDB) DeleteRelationships(ctx context.Context, in *pb.DeleteRelationshipsRequest, opts ...grpc.CallOption) (*pb.DeleteRelationshipsResponse, error) {
+	f.deletes = append(f.deletes, in)
+	return &pb.DeleteRelationshipsResponse{DeletedAt: &pb.ZedToken{Token: "deleted"}}, nil
+}
+
+func (f *fakeSpiceDB) CheckPermission(ctx context.Context, in *pb.CheckPermissionRequest, opts ...grpc.CallOption) (*pb.CheckPermissionResponse, error) {
+	f.checks = append(f.checks, in)
+	return &pb.CheckPermissionResponse{Permissionship: pb.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION}, nil
+}
+`
+
+func TestGenerateSubjectValidation(t *testing.T) {
+	schema := `definition user {}
+caveat on_weekdays(today int) {
+ today < 6
+}
+definition team {
+ relation member: user
+}
+definition document {
+ relation reader: user | team#member | user:* with on_weekdays
+ relation auditor: user with on_weekdays
+ relation owner: team#member
+ permission view = reader + auditor
+}`
+	runGenerated(t, schema, map[string]string{"fake_test.go": fakeSpiceDBSrc, "validation_test.go": `package authz
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSubject(t *testing.T) {
+	ctx := context.Background()
+	doc, user, team := NewDocumentResource("doc"), NewUserResource("alice"), NewTeamResource("eng")
+	weekdays, err := OnWeekdaysContext{}.Caveat()
+	assert.NoError(t, err)
+	other := &pb.ContextualizedCaveat{CaveatName: "other"}
+	tests := []struct {
+		name string
+		call func(c *Client) error
+		err  error
+	}{
+		{name: "add", call: func(c *Client) error { return c.AddRelationship(ctx, doc, "reader", user, nil) }},
+		{name: "add subject relation", call: func(c *Client) error {
+			return c.AddRelationship(ctx, doc, "reader", team, &AddRelationshipOptions{OptionalSubjectRelation: "member"})
+		}},
+		{name: "add caveat", call: func(c *Client) error {
+			return c.AddRelationship(ctx, doc, "auditor", user, &AddRelationshipOptions{Caveat: weekdays})
+		}},
+		{name: "add wildcard", call: func(c *Client) error {
+			return c.AddRelationship(ctx, doc, "reader", UserWildcard, &AddRelationshipOptions{Caveat: weekdays})
+		}},
+		{name: "add subject type", call: func(c *Client) error { return c.AddRelationship(ctx, doc, "auditor", team, nil) }, err: ErrSubjectTypeNotAllowed},
+		{name: "add subject relation not allowed", call: func(c *Client) error { return c.AddRelationship(ctx, doc, "reader", team, nil) }, err: ErrSubjectRelationNotAllowed},
+		{name: "add without caveat", call: func(c *Client) error { return c.AddRelationship(ctx, doc, "auditor", user, nil) }, err: ErrCaveatRequired},
+		{name: "add other caveat", call: func(c *Client) error {
+			return c.AddRelationship(ctx, doc, "auditor", user, &AddRelationshipOptions{Caveat: other})
+		}, err: ErrCaveatNotAllowed},
+		{name: "add caveat not allowed", call: func(c *Client) error {
+			return c.AddRelationship(ctx, doc, "reader", user, &AddRelationshipOptions{Caveat: weekdays})
+		}, err: ErrCaveatNotAllowed},
+		{name: "add unknown relation", call: func(c *Client) error { return c.AddRelationship(ctx, doc, "writer", user, nil) }, err: ErrUnknownRelation},
+		{name: "add permission", call: func(c *Client) error { return c.AddRelationship(ctx, doc, "view", user, nil) }, err: ErrUnknownRelation},
+		{name: "add only subject relation", call: func(c *Client) error {
+			return c.AddRelationship(ctx, doc, "owner", team, &AddRelationshipOptions{OptionalSubjectRelation: "member"})
+		}},
+		{name: "add holder of subject relation", call: func(c *Client) error { return c.AddRelationship(ctx, doc, "owner", user, nil) }, err: ErrSubjectTypeNotAllowed},
+		{name: "add without subject relation", call: func(c *Client) error { return c.AddRelationship(ctx, doc, "owner", team, nil) }, err: ErrSubjectRelationNotAllowed},
+		{name: "delete without caveat", call: func(c *Client) error { return c.DeleteRelationship(ctx, doc, "auditor", user, nil) }},
+		{name: "delete subject type", call: func(c *Client) error { return c.DeleteRelationship(ctx, doc, "auditor", team, nil) }, err: ErrSubjectTypeNotAllowed},
+		{name: "delete permission", call: func(c *Client) error { return c.DeleteRelationship(ctx, doc, "view", user, nil) }, err: ErrUnknownRelation},
+		{name: "check permission", call: func(c *Client) error {
+			_, err := c.CheckPermission(ctx, user, "view", doc, nil)
+			return err
+		}},
+		{name: "check relation", call: func(c *Client) error {
+			_, err := c.CheckPermission(ctx, user, "auditor", doc, nil)
+			return err
+		}},
+		{name: "check relation of subject relation", call: func(c *Client) error {
+			_, err := c.CheckPermission(ctx, user, "owner", doc, nil)
+			return err
+		}},
+		{name: "check relation subject type", call: func(c *Client) error {
+			_, err := c.CheckPermission(ctx, team, "owner", doc, nil)
+			return err
+		}, err: ErrSubjectTypeNotAllowed},
+		{name: "check subject type", call: func(c *Client) error {
+			_, err := c.CheckPermission(ctx, doc, "view", doc, nil)
+			return err
+		}, err: ErrSubjectTypeNotAllowed},
+		{name: "check unknown permission", call: func(c *Client) error {
+			_, err := c.CheckPermission(ctx, user, "edit", doc, nil)
+			return err
+		}, err: ErrUnknownRelation},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			spicedb := &fakeSpiceDB{}
+			err := tc.call(NewClient(spicedb).(*Client))
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				// rejected before making any request
+				assert.Zero(t, spicedb.requests())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 1, spicedb.requests())
+		})
+	}
+}
+`})
+}
@@ -0,0 +1,562 @@
+package gen
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"go/format"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/iancoleman/strcase"
+	"golang.org/x/exp/maps"
+)
+
+//go:embed types.text
+var typestmptext string
+
+//go:embed client.text
+var clienttmptext string
+
+//go:embed resource.text
+var resourcetmptext string
+
+// Go types for the spicedb caveat parameter types
+var caveatGoTypes = map[string]string{
+	"any":       "any",
+	"bool":      "bool",
+	"string":    "string",
+	"int":       "int64",
+	"uint":      "uint64",
+	"double":    "float64",
+	"bytes":     "[]byte",
+	"duration":  "time.Duration",
+	"timestamp": "time.Time",
+	"ipaddress": "net.IP",
+}
+
+func caveatGoType(t CaveatType) string {
+	switch t.Name {
+	case "list":
+		return "[]" + caveatGoType(t.Children[0])
+	case "map":
+		return "map[string]" + caveatGoType(t.Children[0])
+	}
+	if goType, ok := caveatGoTypes[t.Name]; ok {
+		return goType
+	}
+	return "any"
+}
+
+// Returns an expression converting expr of the given caveat type into a value accepted by structpb.NewValue. Durations,
+// timestamps and ip addresses are sent as strings, which is how spicedb parses them out of a caveat context.
+func caveatValue(t CaveatType, expr string) string {
+	switch t.Name {
+	case "list":
+		return fmt.Sprintf("caveatList(%s, func(v %s) any { return %s })", expr, caveatGoType(t.Children[0]), caveatValue(t.Children[0], "v"))
+	case "map":
+		return fmt.Sprintf("caveatMap(%s, func(v %s) any { return %s })", expr, caveatGoType(t.Children[0]), caveatValue(t.Children[0], "v"))
+	case "duration", "ipaddress":
+		return expr + ".String()"
+	case "timestamp":
+		return expr + ".Format(time.RFC3339)"
+	}
+	return expr
+}
+
+// Whether the Go type for the caveat type can be nil. Nil args are left out of the context.
+func caveatNillable(t CaveatType) bool {
+	switch t.Name {
+	case "list", "map", "bytes", "ipaddress", "any":
+		return true
+	}
+	_, ok := caveatGoTypes[t.Name]
+	return !ok
+}
+
+// Returns the Go type of a caveat context field. Scalars are pointers, so unset fields are left out of the context
+// rather than written as zero values, which spicedb would use over the context given at check time.
+func caveatFieldType(t CaveatType) string {
+	if caveatNillable(t) {
+		return caveatGoType(t)
+	}
+	return "*" + caveatGoType(t)
+}
+
+// Returns an expression converting the non-nil caveat context field into a value accepted by structpb.NewValue
+func caveatFieldValue(t CaveatType, field string) string {
+	switch {
+	case caveatNillable(t):
+		return caveatValue(t, field)
+	case t.Name == "duration" || t.Name == "timestamp":
+		// the methods formatting them dereference the pointer
+		return caveatValue(t, field)
+	}
+	return "*" + field
+}
+
+// Returns the std imports needed by the caveat context types
+func caveatImports(caveats []Caveat) []string {
+	imports := map[string]bool{}
+	var walk func(t CaveatType)
+	walk = func(t CaveatType) {
+		switch t.Name {
+		case "ipaddress":
+			imports["net"] = true
+		case "duration", "timestamp":
+			imports["time"] = true
+		}
+		for _, child := range t.Children {
+			walk(child)
+		}
+	}
+	for _, caveat := range caveats {
+		for _, arg := range caveat.ArgsArray {
+			walk(arg.Type)
+		}
+	}
+	res := maps.Keys(imports)
+	sort.Strings(res)
+	return res
+}
+
+// Returns the Go type for a resolved subject type, either the generated concrete resource or the Resource interface.
+func subjectType(name string) string {
+	if name == "resource" {
+		return "Resource"
+	}
+	return goName(name) + "Resource"
+}
+
+// Returns the Go type for the subject of a resource's relations, which is the sealed union interface when the
+// relations accept more than one resource.
+func relationSubjectType(rsc Resource) string {
+	if rsc.RelationSubjectUnion != nil {
+		return rsc.GoName + "RelationSubject"
+	}
+	return subjectType(rsc.RelationSubjectType)
+}
+
+// A sealed interface, implemented only by the resources that can be the subject of a relation
+type subjectUnion struct {
+	Name        string
+	Description string
+	Types       []string
+}
+
+func subjectUnions(resources []Resource) []subjectUnion {
+	unions := make([]subjectUnion, 0)
+	seen := map[string]bool{}
+	for _, rsc := range resources {
+		resource := rsc.GoName
+		if rsc.RelationSubjectUnion != nil {
+			name := relationSubjectType(rsc)
+			seen[name] = true
+			unions = append(unions, subjectUnion{Name: name, Description: fmt.Sprintf("the %s relations", rsc.Name), Types: rsc.RelationSubjectUnion})
+		}
+		for _, rel := range rsc.RelationsArray {
+			name := resource + strcase.ToCamel(rel.OutputName) + "Subject"
+			if rel.SubjectUnion == nil || seen[name] {
+				continue
+			}
+			seen[name] = true
+			unions = append(unions, subjectUnion{Name: name, Description: fmt.Sprintf("the %s %s relation", rsc.Name, rel.Name), Types: rel.SubjectUnion})
+		}
+	}
+	return unions
+}
+
+// Returns the subjects spicedb accepts for the relation or permission, used to build the runtime allowed subjects
+// table. Subject type overrides are paired with any caveats the schema requires for the same subject.
+func allowedSubjects(rel Relation) []RelationRef {
+	refs := make([]RelationRef, 0)
+	if rel.Kind == "permission" {
+		for subjectType, subjectRelation := range rel.SubjectTypes() {
+			refs = append(refs, RelationRef{ResourceType: subjectType, Relation: subjectRelation})
+		}
+	} else if rel.OverrideAllowedSubjectTypes != nil {
+		for subjectType, subjectRelation := range rel.OverrideAllowedSubjectTypes {
+			found := false
+			for _, ref := range rel.RelationRefs {
+				if ref.ResourceType == subjectType && ref.Relation == subjectRelation {
+					refs = append(refs, ref)
+					found = true
+				}
+			}
+			if !found {
+				refs = append(refs, RelationRef{ResourceType: subjectType, Relation: subjectRelation})
+			}
+		}
+	} else {
+		refs = append(refs, rel.RelationRefs...)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].ResourceType != refs[j].ResourceType {
+			return refs[i].ResourceType < refs[j].ResourceType
+		}
+		if refs[i].Relation != refs[j].Relation {
+			return refs[i].Relation < refs[j].Relation
+		}
+		if refs[i].Wildcard != refs[j].Wildcard {
+			return !refs[i].Wildcard
+		}
+		return refs[i].Caveat < refs[j].Caveat
+	})
+	return refs
+}
+
+// Returns the subject types allowed as a public wildcard (i.e. user:*) by any of the resources' relations.
+func wildcardTypes(resources []Resource) []string {
+	types := map[string]bool{}
+	for _, rsc := range resources {
+		for _, rel := range rsc.RelationsArray {
+			for _, ref := range allowedSubjects(rel) {
+				if ref.Wildcard {
+					types[ref.ResourceType] = true
+				}
+			}
+		}
+	}
+	res := maps.Keys(types)
+	sort.Strings(res)
+	return res
+}
+
+// A typed writer for the relationships of a relation with one of its caveats, i.e. AddDocumentReaderWithIpAllowlist
+type caveatWriter struct {
+	Name        string
+	Relation    Relation
+	Caveat      string // the caveat name, i.e. ip_allowlist
+	SubjectType string // the Go type of the subject
+	// The subject relation of every subject the schema allows with the caveat, i.e. member for team#member. Empty if
+	// they are objects or differ.
+	SubjectRelation string
+	Subjects        string // the subjects the schema allows with the caveat, i.e. user, team#member
+}
+
+// Returns a writer for each caveat the relations of the resource allow, sorted by relation and caveat. The subject is
+// typed when the schema allows the caveat for a single generated resource, only for its wildcard, or for exactly the
+// resources of the relation's subject union.
+func caveatWriters(resources map[string]Resource, rsc Resource) []caveatWriter {
+	writers := make([]caveatWriter, 0)
+	for _, rel := range rsc.RelationsArray {
+		byCaveat := map[string][]RelationRef{}
+		for _, ref := range allowedSubjects(rel) {
+			if ref.Caveat != "" {
+				byCaveat[ref.Caveat] = append(byCaveat[ref.Caveat], ref)
+			}
+		}
+		caveats := maps.Keys(byCaveat)
+		sort.Strings(caveats)
+		for _, caveat := range caveats {
+			subjects := byCaveat[caveat]
+			names := make([]string, len(subjects))
+			for i, ref := range subjects {
+				ref.Caveat = ""
+				names[i] = ref.String()
+			}
+			subject, subjectRelation := typedSubject(resources, rsc, rel, subjects)
+			writers = append(writers, caveatWriter{
+				Name:            "Add" + rsc.GoName + strcase.ToCamel(rel.OutputName) + "With" + goName(caveat),
+				Relation:        rel,
+				Caveat:          caveat,
+				SubjectType:     subject,
+				SubjectRelation: subjectRelation,
+				Subjects:        strings.Join(names, ", "),
+			})
+		}
+	}
+	return writers
+}
+
+// Writers for the relationships of a single relation, i.e. AddOrganizationAdministrator and
+// DeleteOrganizationAdministrator, taking the subjects the relation allows rather than any subject of the resource
+type relationWriter struct {
+	Relation Relation
+	Add      string // the name of the writer, empty if the relation only allows caveated subjects
+	Delete   string // the name of the deleter
+	// The Go types of the subjects, i.e. the relation's sealed subject interface. Relationships with a caveat can be
+	// deleted, but are only written by the caveat writers.
+	AddSubjectType    string
+	DeleteSubjectType string
+	// The subject relation of every subject, i.e. member for team#member. Empty if they are objects or differ.
+	AddSubjectRelation    string
+	DeleteSubjectRelation string
+}
+
+// Returns the writers of each relation of the resource, sorted by relation. The subject is typed like the subject of
+// the caveat writers.
+func relationWriters(resources map[string]Resource, rsc Resource) []relationWriter {
+	writers := make([]relationWriter, 0)
+	for _, rel := range rsc.RelationsArray {
+		name := rsc.GoName + strcase.ToCamel(rel.OutputName)
+		// wildcards are written with the public writers, unless the relation only allows wildcards
+		subjects := allowedSubjects(rel)
+		if objects := slices.DeleteFunc(slices.Clone(subjects), func(ref RelationRef) bool { return ref.Wildcard }); len(objects) > 0 {
+			subjects = objects
+		}
+		uncaveated := slices.DeleteFunc(slices.Clone(subjects), func(ref RelationRef) bool { return ref.Caveat != "" })
+		writer := relationWriter{Relation: rel, Delete: "Delete" + name}
+		writer.DeleteSubjectType, writer.DeleteSubjectRelation = typedSubject(resources, rsc, rel, subjects)
+		if len(uncaveated) > 0 {
+			writer.Add = "Add" + name
+			writer.AddSubjectType, writer.AddSubjectRelation = typedSubject(resources, rsc, rel, uncaveated)
+		}
+		writers = append(writers, writer)
+	}
+	return writers
+}
+
+// Returns the Go type for the given subjects of the relation and their subject relation, if they all have the same.
+// The subject is typed when the subjects are a single generated resource, only its wildcard, or exactly the resources
+// of the relation's subject union, and is Resource otherwise.
+func typedSubject(resources map[string]Resource, rsc Resource, rel Relation, subjects []RelationRef) (string, string) {
+	if len(subjects) == 0 {
+		return "Resource", ""
+	}
+	types, wildcard, relations := map[string]bool{}, false, map[string]bool{}
+	for _, ref := range subjects {
+		relations[ref.Relation] = true
+		if ref.Wildcard {
+			wildcard = true
+		} else {
+			types[ref.ResourceType] = true
+		}
+	}
+	typeNames := maps.Keys(types)
+	sort.Strings(typeNames)
+	subject := "Resource"
+	switch _, ok := resources[subjects[0].ResourceType]; {
+	case len(types) == 0 && wildcard:
+		subject = "Wildcard"
+	case len(types) == 1 && !wildcard && ok:
+		subject = subjectType(subjects[0].ResourceType)
+	case !wildcard && rel.SubjectUnion != nil && strings.Join(typeNames, ",") == strings.Join(rel.SubjectUnion, ","):
+		subject = rsc.GoName + strcase.ToCamel(rel.OutputName) + "Subject"
+	}
+	subjectRelation := ""
+	if relation := subjects[0].Relation; len(relations) == 1 && relation != "" && relation != "..." {
+		subjectRelation = relation
+	}
+	return subject, subjectRelation
+}
+
+// Formats the doc text and deprecation notice as a Go doc comment, or an empty string if there are neither
+func docComment(doc, deprecated string) string {
+	lines := make([]string, 0)
+	if doc != "" {
+		lines = append(lines, strings.Split(doc, "\n")...)
+	}
+	if deprecated != "" {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "Deprecated: "+deprecated)
+	}
+	buf := &strings.Builder{}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "\t") {
+			// code block
+			buf.WriteString("//" + line + "\n")
+			continue
+		}
+		buf.WriteString(strings.TrimRight("// "+line, " ") + "\n")
+	}
+	return buf.String()
+}
+
+// Returns the doc text for the generated resource type, carrying over the definition's doc comment
+func resourceDoc(rsc Resource) string {
+	if rsc.Doc == "" {
+		return ""
+	}
+	return fmt.Sprintf("%sResource is an object of the %s definition.\n\n%s", rsc.GoName, rsc.Name, rsc.Doc)
+}
+
+// Returns the doc text for the generated relation or permission constant, carrying over the relation's doc comment
+// and the rewrite expression of permissions.
+func relationDoc(rsc Resource, rel Relation) string {
+	if rel.Doc == "" && rel.Expression == "" {
+		return ""
+	}
+	kind := "Relation"
+	if rel.Kind == "permission" {
+		kind = "Permission"
+	}
+	paragraphs := []string{fmt.Sprintf("%s%s%s is the %s %s %s.", rsc.ConstPrefix, strcase.ToCamel(rel.OutputName), kind, rsc.Name, rel.Name, rel.Kind)}
+	if rel.Doc != "" {
+		paragraphs = append(paragraphs, rel.Doc)
+	}
+	if rel.Expression != "" {
+		paragraphs = append(paragraphs, fmt.Sprintf("\t%s = %s", rel.Name, rel.Expression))
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// Renders the template with the context. path is the path of the generated file, relative to the output directory.
+func genSource(context any, templateTxt, path string) (GeneratedFile, error) {
+	tmpl, err := template.New(path).Funcs(templateFuncs()).Parse(templateTxt)
+	if err != nil {
+		return GeneratedFile{}, &GenerateError{Path: path, Err: fmt.Errorf("error parsing template: %w", err)}
+	}
+	buf := &strings.Builder{}
+	if err := tmpl.Execute(buf, context); err != nil {
+		return GeneratedFile{}, &GenerateError{Path: path, Err: fmt.Errorf("error executing template: %w", err)}
+	}
+	return GeneratedFile{Path: path, Content: []byte(buf.String())}, nil
+}
+
+// Renders the template with the context and formats the result as Go source
+func genFormattedSource(context any, templateTxt, path string) (GeneratedFile, error) {
+	file, err := genSource(context, templateTxt, path)
+	if err != nil {
+		return file, err
+	}
+	res, err := format.Source(file.Content)
+	if err != nil {
+		return GeneratedFile{}, &GenerateError{Path: path, Err: fmt.Errorf("error formatting source: %w", err), Source: string(file.Content)}
+	}
+	return GeneratedFile{Path: path, Content: res}, nil
+}
+
+// The emitter registered as go, generating the client, types and permissions packages
+type goEmitter struct{}
+
+func (goEmitter) Emit(schema Schema, cfg Config) ([]GeneratedFile, error) {
+	return emitGo(context.Background(), schema, cfg)
+}
+
+func (goEmitter) emitContext(ctx context.Context, schema Schema, cfg Config) ([]GeneratedFile, error) {
+	return emitGo(ctx, schema, cfg)
+}
+
+func emitGo(ctx context.Context, schema Schema, cfg Config) ([]GeneratedFile, error) {
+	tmpls, err := loadTemplates(cfg.TemplateDir)
+	if err != nil {
+		return nil, err
+	}
+	// the permissions packages are laid out on a copy, other emitters get the schema as is
+	resources := append([]Resource{}, schema.ResourcesArray...)
+	if err := layoutEnums(resources, cfg); err != nil {
+		return nil, err
+	}
+	schema.ResourcesArray = resources
+	schema.Resources = map[string]Resource{}
+	for _, rsc := range resources {
+		schema.Resources[rsc.Name] = rsc
+	}
+	caveats := schema.CaveatsArray
+	owners, err := checkIdentifiers(resources, caveats, cfg)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]GeneratedFile, 0)
+	sources := map[string]goSource{}
+	file, err := genTypes(schema, resources, caveats, cfg, tmpls.Types)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, file)
+	sources[file.Path] = goSource{template: "types.text", owners: owners["."]}
+	if !cfg.SkipClient {
+		file, err := genClient(schema, resources, cfg, tmpls.Client)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+		sources[file.Path] = goSource{template: "client.text", owners: owners["."]}
+	}
+	for _, rsc := range resources {
+		if cfg.Layout == LayoutFlat && len(rsc.Permissions) == 0 && len(rsc.Relations) == 0 {
+			continue
+		}
+		file, err := genResource(schema, rsc, cfg, tmpls.Resource)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+		pkgOwners := owners[path.Dir(file.Path)]
+		if cfg.Layout == LayoutFlat {
+			pkgOwners = owners["."]
+		}
+		sources[file.Path] = goSource{template: "resource.text", element: "definition " + rsc.Name, owners: pkgOwners}
+	}
+	for _, tmpl := range tmpls.Extra {
+		if tmpl.Path == cfg.ClientFileName {
+			return nil, fmt.Errorf("template %s would overwrite the client %s", tmpl.Name, cfg.ClientFileName)
+		}
+		file, err := genExtra(schema, resources, caveats, cfg, tmpl)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+		sources[file.Path] = goSource{template: tmpl.Name, owners: owners["."]}
+	}
+	if cfg.Verify {
+		if err := verifyGo(ctx, files, sources, cfg); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func genTypes(schema Schema, resources []Resource, caveats []Caveat, cfg Config, templateTxt string) (GeneratedFile, error) {
+	// the interface is empty for a schema of definitions without relations, i.e. definition user {}
+	interfaceMethods := false
+	for _, rsc := range resources {
+		interfaceMethods = interfaceMethods || len(rsc.Permissions) > 0 || len(rsc.Relations) > 0
+	}
+	return genFormattedSource(struct {
+		PackageName      string
+		InterfaceName    string
+		InterfaceMethods bool
+		ImportPath       string
+		Schema           Schema
+		Resources        []Resource
+		Caveats          []Caveat
+		CaveatImports    []string
+		SubjectUnions    []subjectUnion
+	}{PackageName: cfg.PackageName, InterfaceName: cfg.InterfaceName, InterfaceMethods: interfaceMethods, ImportPath: cfg.ImportPath, Schema: schema, Resources: resources, Caveats: caveats, CaveatImports: caveatImports(caveats), SubjectUnions: subjectUnions(resources)}, templateTxt, "types.go")
+}
+
+func genClient(schema Schema, resources []Resource, cfg Config, templateTxt string) (GeneratedFile, error) {
+	return genFormattedSource(struct {
+		PackageName   string
+		ClientName    string
+		InterfaceName string
+		ImportPath    string
+		ObjectPrefix  string
+		Schema        Schema
+		Resources     []Resource
+	}{PackageName: cfg.PackageName, ClientName: cfg.ClientName, InterfaceName: cfg.InterfaceName, ImportPath: cfg.ImportPath, ObjectPrefix: cfg.ObjectPrefix, Schema: schema, Resources: resources}, templateTxt, cfg.ClientFileName)
+}
+
+// Generates the enums of a resource, in its own package or in the client package in the flat layout
+func genResource(schema Schema, rsc Resource, cfg Config, templateTxt string) (GeneratedFile, error) {
+	packageName, filePath := rsc.PackageName, path.Join(cfg.EnumDir, rsc.Name, rsc.PackageName+".go")
+	if cfg.Layout == LayoutFlat {
+		packageName, filePath = cfg.PackageName, strings.ReplaceAll(rsc.Name, "/", "_")+"_enums.go"
+	}
+	return genFormattedSource(struct {
+		PackageName string
+		Schema      Schema
+		Resource    Resource
+	}{PackageName: packageName, Schema: schema, Resource: rsc}, templateTxt, filePath)
+}
+
+// Renders a user-supplied template in the client package with everything the types and client templates receive
+func genExtra(schema Schema, resources []Resource, caveats []Caveat, cfg Config, tmpl extraTemplate) (GeneratedFile, error) {
+	return genFormattedSource(struct {
+		PackageName   string
+		ClientName    string
+		InterfaceName string
+		ImportPath    string
+		ObjectPrefix  string
+		Schema        Schema
+		Resources     []Resource
+		Caveats       []Caveat
+	}{PackageName: cfg.PackageName, ClientName: cfg.ClientName, InterfaceName: cfg.InterfaceName, ImportPath: cfg.ImportPath, ObjectPrefix: cfg.ObjectPrefix, Schema: schema, Resources: resources, Caveats: caveats}, tmpl.Text, tmpl.Path)
+}
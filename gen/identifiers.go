@@ -0,0 +1,251 @@
+package gen
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/iancoleman/strcase"
+)
+
+// The names imported by the generated client package. Permissions packages are aliased so they don't shadow these.
+var clientImports = []string{"context", "errors", "fmt", "io", "net", "pb", "structpb", "sync", "time"}
+
+// Whether name can't be used to import a permissions package into the client package, because it is a keyword,
+// shadows a builtin or import, or is already taken
+func reservedImportName(name string, taken map[string]bool) bool {
+	return taken[name] || token.IsKeyword(name) || types.Universe.Lookup(name) != nil || name == "init"
+}
+
+// Renames the permissions packages that can't be used as Go package names (i.e. a definition named type or main),
+// and aliases their imports in the client package when they would shadow an import or builtin (i.e. a definition
+// named context or string) or another permissions package (i.e. billing/invoice and billing_invoice).
+func aliasPackages(resources []Resource) {
+	taken := map[string]bool{}
+	for _, name := range clientImports {
+		taken[name] = true
+	}
+	for i := range resources {
+		rsc := &resources[i]
+		if token.IsKeyword(rsc.PackageName) || rsc.PackageName == "main" || rsc.PackageName == "init" {
+			rsc.PackageName += "pkg"
+		}
+		alias := rsc.PackageAlias
+		for n := 1; reservedImportName(alias, taken); n++ {
+			alias = rsc.PackageAlias + "pkg"
+			if n > 1 {
+				alias += fmt.Sprint(n)
+			}
+		}
+		rsc.PackageAlias = alias
+		taken[alias] = true
+	}
+}
+
+// Sets the package, import path and names of each resource's enums for the layout
+func layoutEnums(resources []Resource, cfg Config) error {
+	switch cfg.Layout {
+	case LayoutFlat:
+		for i := range resources {
+			resources[i].ConstPrefix = resources[i].GoName
+		}
+		return nil
+	case LayoutPackages:
+	default:
+		return fmt.Errorf("unknown layout %q, must be %s or %s", cfg.Layout, LayoutPackages, LayoutFlat)
+	}
+	if !filepath.IsLocal(cfg.EnumDir) {
+		return fmt.Errorf("enum directory %q must be a relative path within the output directory", cfg.EnumDir)
+	}
+	aliasPackages(resources)
+	for i := range resources {
+		resources[i].EnumImportPath = path.Join(cfg.ImportPath, filepath.ToSlash(cfg.EnumDir), resources[i].Name)
+		resources[i].EnumQualifier = resources[i].PackageAlias + "."
+	}
+	return nil
+}
+
+// The Go identifiers declared in a scope, i.e. a package, mapped to the schema element declaring them
+type identifiers struct {
+	scope  string
+	owners map[string]string
+}
+
+func newIdentifiers(scope string, reserved ...string) identifiers {
+	ids := identifiers{scope: scope, owners: map[string]string{}}
+	for _, name := range reserved {
+		ids.owners[name] = ""
+	}
+	return ids
+}
+
+func (ids identifiers) add(name, owner string) error {
+	other, ok := ids.owners[name]
+	switch {
+	case !ok:
+		ids.owners[name] = owner
+		return nil
+	case other == "":
+		return fmt.Errorf("%s generates the Go identifier %s, which spicegen already declares in %s", owner, name, ids.scope)
+	}
+	return fmt.Errorf("%s and %s both generate the Go identifier %s in %s", other, owner, name, ids.scope)
+}
+
+// Checks that the schema elements don't generate the same Go identifier as each other or as spicegen itself, i.e.
+// definitions billing/invoice and billing_invoice both generate BillingInvoice, and a definition named resource
+// generates the Resource constant, which collides with the Resource interface. Returns the schema element declaring
+// each identifier of the generated packages, keyed by their directory, to map type errors back to the schema.
+func checkIdentifiers(resources []Resource, caveats []Caveat, cfg Config) (map[string]map[string]string, error) {
+	reserved := []string{"ResourceType", "Resource", "NewResource", cfg.InterfaceName, "CheckPermissionOptions",
+		"AddRelationshipOptions", "DeleteRelationshipOptions", "LookupResourcesOptions", "LookupSubjectsOptions",
+		"LookupSubjectsResult", "Pagination"}
+	wildcards := wildcardTypes(resources)
+	if len(wildcards) > 0 {
+		reserved = append(reserved, "Wildcard")
+	}
+	if len(caveats) > 0 {
+		reserved = append(reserved, "CaveatContext", "caveatList", "caveatMap")
+	}
+	methods := []string{}
+	if !cfg.SkipClient {
+		reserved = append(reserved, "SpiceDBClient", cfg.ClientName, cfg.ClientName+"Option", "New"+cfg.ClientName,
+			"DefaultObjectPrefix", "WithObjectPrefix", "ErrUnknownRelation", "ErrSubjectTypeNotAllowed",
+			"ErrSubjectRelationNotAllowed", "ErrCaveatRequired", "ErrCaveatNotAllowed", "allowedSubject",
+			"allowedRelation", "allowedSubjects", "validation", "validateCheck", "validateDelete", "validateWrite",
+			"validateSubject")
+		// including the methods promoted from the embedded sync.RWMutex
+		methods = append(methods, "CheckPermission", "AddRelationship", "DeleteRelationship", "LookupResources",
+			"LookupSubjects", "getConsistency", "prefixed", "Lock", "Unlock", "RLock", "RUnlock", "TryLock", "TryRLock",
+			"RLocker")
+	}
+	pkg := newIdentifiers("package "+cfg.PackageName, reserved...)
+	client := newIdentifiers("the methods of "+cfg.ClientName, methods...)
+	owners := map[string]map[string]string{".": pkg.owners}
+	schemaResources := map[string]Resource{}
+	for _, rsc := range resources {
+		schemaResources[rsc.Name] = rsc
+	}
+	for _, rsc := range resources {
+		owner := "definition " + rsc.Name
+		names := []string{rsc.GoName, rsc.GoName + "Resource", "New" + rsc.GoName + "Resource"}
+		for _, t := range wildcards {
+			if t == rsc.Name {
+				names = append(names, rsc.GoName+"Wildcard")
+			}
+		}
+		if rsc.RelationSubjectUnion != nil {
+			names = append(names, relationSubjectType(rsc))
+		}
+		for _, name := range names {
+			if err := pkg.add(name, owner); err != nil {
+				return nil, err
+			}
+		}
+		for _, rel := range rsc.RelationsArray {
+			if rel.SubjectUnion == nil {
+				continue
+			}
+			if err := pkg.add(rsc.GoName+strcase.ToCamel(rel.OutputName)+"Subject", fmt.Sprintf("relation %s#%s", rsc.Name, rel.Name)); err != nil {
+				return nil, err
+			}
+		}
+		if !cfg.SkipClient {
+			names = []string{}
+			if len(rsc.Permissions) > 0 {
+				names = append(names, "Check"+rsc.GoName+"Permission", "Lookup"+rsc.GoName+"Resources", "Lookup"+rsc.GoName+"Subjects")
+			}
+			if len(rsc.Relations) > 0 {
+				names = append(names, "Add"+rsc.GoName+"Relationship", "Delete"+rsc.GoName+"Relationship")
+			}
+			if len(wildcardTypes([]Resource{rsc})) > 0 {
+				names = append(names, "Add"+rsc.GoName+"RelationshipPublic", "Delete"+rsc.GoName+"RelationshipPublic")
+			}
+			for _, name := range names {
+				if err := client.add(name, owner); err != nil {
+					return nil, err
+				}
+			}
+		}
+		enums := pkg
+		if cfg.Layout != LayoutFlat {
+			enums = newIdentifiers("package " + rsc.PackageName)
+			owners[path.Join(filepath.ToSlash(cfg.EnumDir), rsc.Name)] = enums.owners
+		}
+		if err := checkEnums(rsc, enums); err != nil {
+			return nil, err
+		}
+		if cfg.SkipClient {
+			continue
+		}
+		// the writers of a relation are named after it, so relations colliding there are reported as enums first
+		for _, w := range relationWriters(schemaResources, rsc) {
+			for _, name := range []string{w.Add, w.Delete} {
+				if name == "" {
+					continue
+				}
+				if err := client.add(name, fmt.Sprintf("relation %s#%s", rsc.Name, w.Relation.Name)); err != nil {
+					return nil, err
+				}
+			}
+		}
+		for _, w := range caveatWriters(schemaResources, rsc) {
+			if err := client.add(w.Name, fmt.Sprintf("relation %s#%s with %s", rsc.Name, w.Relation.Name, w.Caveat)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, caveat := range caveats {
+		owner := "caveat " + caveat.Name
+		if err := pkg.add(goName(caveat.Name)+"Context", owner); err != nil {
+			return nil, err
+		}
+		fields := newIdentifiers("the fields of "+goName(caveat.Name)+"Context", "CaveatName", "Struct", "Caveat")
+		for _, arg := range caveat.ArgsArray {
+			if err := fields.add(strcase.ToCamel(arg.Name), fmt.Sprintf("parameter %s of %s", arg.Name, owner)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// the methods of the client are declared in the client package too
+	for name, owner := range client.owners {
+		if _, ok := pkg.owners[name]; !ok {
+			pkg.owners[name] = owner
+		}
+	}
+	return owners, nil
+}
+
+// Checks the enums of a definition, i.e. relations doc_type and doc__type both generate DocTypeRelation
+func checkEnums(rsc Resource, ids identifiers) error {
+	if len(rsc.Permissions) > 0 {
+		if err := ids.add(rsc.GoName+"Permission", "definition "+rsc.Name); err != nil {
+			return err
+		}
+	}
+	if len(rsc.Relations) > 0 {
+		if err := ids.add(rsc.GoName+"Relation", "definition "+rsc.Name); err != nil {
+			return err
+		}
+	}
+	for _, enum := range []struct {
+		suffix string
+		rels   []Relation
+	}{{"Permission", rsc.PermissionsArray}, {"Relation", rsc.RelationsArray}} {
+		suffix := enum.suffix
+		for _, rel := range enum.rels {
+			owner := fmt.Sprintf("%s %s#%s", strings.ToLower(suffix), rsc.Name, rel.Name)
+			if err := ids.add(rsc.ConstPrefix+strcase.ToCamel(rel.OutputName)+suffix, owner); err != nil {
+				return err
+			}
+			for _, alias := range rel.Aliases {
+				if err := ids.add(rsc.ConstPrefix+strcase.ToCamel(alias)+suffix, fmt.Sprintf("alias %s of %s", alias, owner)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package gen
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAliasPackages(t *testing.T) {
+	resources := []Resource{
+		{Name: "billing/invoice", PackageName: "invoice", PackageAlias: "billing_invoice"},
+		{Name: "billing_invoice", PackageName: "billing_invoice", PackageAlias: "billing_invoice"},
+		{Name: "context", PackageName: "context", PackageAlias: "context"},
+		{Name: "contextpkg", PackageName: "contextpkg", PackageAlias: "contextpkg"},
+		{Name: "document", PackageName: "document", PackageAlias: "document"},
+		{Name: "main", PackageName: "main", PackageAlias: "main"},
+		{Name: "string", PackageName: "string", PackageAlias: "string"},
+		{Name: "type", PackageName: "type", PackageAlias: "type"},
+	}
+	aliasPackages(resources)
+	actual := map[string][2]string{}
+	for _, rsc := range resources {
+		actual[rsc.Name] = [2]string{rsc.PackageName, rsc.PackageAlias}
+	}
+	assert.Equal(t, map[string][2]string{
+		"billing/invoice": {"invoice", "billing_invoice"},
+		"billing_invoice": {"billing_invoice", "billing_invoicepkg"},
+		"context":         {"context", "contextpkg"},
+		"contextpkg":      {"contextpkg", "contextpkgpkg"},
+		"document":        {"document", "document"},
+		"main":            {"mainpkg", "main"},
+		"string":          {"string", "stringpkg"},
+		"type":            {"typepkg", "typepkg"},
+	}, actual)
+}
+
+func TestCheckIdentifiers(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		cfg    Config
+		err    string
+	}{
+		{
+			name:   "definitions with the same Go name",
+			schema: "definition billing/invoice {}\ndefinition billing_invoice {}",
+			err:    "definition billing/invoice and definition billing_invoice both generate the Go identifier BillingInvoice in package authz",
+		},
+		{
+			name:   "definition colliding with the generated types",
+			schema: "definition resource {}",
+			err:    "definition resource generates the Go identifier Resource, which spicegen already declares in package authz",
+		},
+		{
+			name:   "definition colliding with the generated resource of another",
+			schema: "definition user {}\ndefinition user_resource {}",
+			err:    "definition user and definition user_resource both generate the Go identifier UserResource in package authz",
+		},
+		{
+			name:   "definition colliding with the client",
+			schema: "definition client {}",
+			err:    "definition client generates the Go identifier Client, which spicegen already declares in package authz",
+		},
+		{
+			name:   "definition named like the client without a client",
+			schema: "definition client {}",
+			cfg:    Config{SkipClient: true},
+		},
+		{
+			name:   "relations with the same Go name",
+			schema: "definition user {}\ndefinition document {\n relation doc_type: user\n relation doc__type: user\n}",
+			err:    "relation document#doc__type and relation document#doc_type both generate the Go identifier DocTypeRelation in package document",
+		},
+		{
+			name:   "renamed relation colliding with another",
+			schema: "definition user {}\ndefinition document {\n relation reader: user\n /** //spicegen:rename=reader */\n relation viewer: user\n}",
+			err:    "relation document#reader and relation document#viewer both generate the Go identifier ReaderRelation in package document",
+		},
+		{
+			name:   "permission colliding with the permission type",
+			schema: "definition user {}\ndefinition document {\n relation reader: user\n permission document = reader\n}",
+			err:    "definition document and permission document#document both generate the Go identifier DocumentPermission in package document",
+		},
+		{
+			name:   "caveat parameter colliding with a method",
+			schema: "caveat flagged(struct bool) {\n struct\n}\ndefinition user {}",
+			err:    "parameter struct of caveat flagged generates the Go identifier Struct, which spicegen already declares in the fields of FlaggedContext",
+		},
+		{
+			name:   "enum colliding with a definition in the flat layout",
+			schema: "definition user {}\ndefinition document {\n relation reader: user\n}\ndefinition document_reader_relation {}",
+			cfg:    Config{Layout: LayoutFlat},
+			err:    "relation document#reader and definition document_reader_relation both generate the Go identifier DocumentReaderRelation in package authz",
+		},
+		{
+			name:   "caveated writers with the same name",
+			schema: "definition user {}\ncaveat bar_with_baz(x int) {\n x > 0\n}\ncaveat baz(x int) {\n x > 0\n}\ndefinition document {\n relation doc: user with bar_with_baz\n relation doc_with_bar: user with baz\n}",
+			err:    "relation document#doc with bar_with_baz and relation document#doc_with_bar with baz both generate the Go identifier AddDocumentDocWithBarWithBaz in the methods of Client",
+		},
+		{
+			name:   "relation writer colliding with the resource writer",
+			schema: "definition user {}\ndefinition document {\n relation relationship: user\n}",
+			err:    "definition document and relation document#relationship both generate the Go identifier AddDocumentRelationship in the methods of Client",
+		},
+		{
+			name:   "definitions shadowing imports",
+			schema: "definition user {}\ndefinition context {\n relation member: user\n}\ndefinition errors {\n relation member: user | context#member\n}",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.cfg.Schema = tc.schema
+			tc.cfg.PackageName = "authz"
+			tc.cfg.ImportPath = "github.com/ben-mays/spicegen/example"
+			_, err := Generate(context.Background(), tc.cfg)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package gen
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	corev1 "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaFileExt is the extension of schema files picked up when a directory is given as input
+const SchemaFileExt = ".zed"
+
+// Expands the given schema inputs into a list of files. Each input may be a file, a directory (all .zed files within it,
+// recursively) or a glob. Files are returned in input order, with the files of a directory or glob sorted by path, and
+// a file given more than once is only returned the first time.
+func ResolveSchemaFiles(inputs []string) ([]string, error) {
+	files := make([]string, 0)
+	seen := map[string]bool{}
+	add := func(file string) {
+		file = filepath.Clean(file)
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	for _, in := range inputs {
+		matches, err := filepath.Glob(in)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema file pattern %q: %w", in, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no schema files found for %q", in)
+		}
+		sort.Strings(matches)
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				add(match)
+				continue
+			}
+			found := make([]string, 0)
+			err = filepath.WalkDir(match, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if !d.IsDir() && filepath.Ext(path) == SchemaFileExt {
+					found = append(found, path)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			if len(found) == 0 {
+				return nil, fmt.Errorf("no %s files found in directory %q", SchemaFileExt, match)
+			}
+			for _, file := range found {
+				add(file)
+			}
+		}
+	}
+	return files, nil
+}
+
+// Compiles each schema file on its own and merges the results, so that compile errors point at the file and line they
+// occur in. Definitions may reference definitions and caveats from any of the files, but must only be defined once.
+func CompileSchemaFiles(files []string) (*compiler.CompiledSchema, error) {
+	compiled, _, err := compileSchemaFiles(files)
+	return compiled, err
+}
+
+// Same as CompileSchemaFiles, also returning the source each definition and caveat is defined in. A schema file is
+// only compiled once, even when validation files point at it or it is also given directly, i.e. schema/* matching
+// both schema.zed and the schema.zed.yaml using it.
+func compileSchemaFiles(files []string) (*compiler.CompiledSchema, map[string]string, error) {
+	merged := &compiler.CompiledSchema{}
+	definedIn := map[string]string{}
+	compiledSources := map[string]bool{}
+	for _, file := range files {
+		source, schematxt, err := readSchemaFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		if compiledSources[source] {
+			continue
+		}
+		compiledSources[source] = true
+		compiled, err := compileSchema(source, schematxt)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, def := range compiled.OrderedDefinitions {
+			if prev, ok := definedIn[def.GetName()]; ok {
+				var pos *corev1.SourcePosition
+				switch def := def.(type) {
+				case *corev1.NamespaceDefinition:
+					pos = def.SourcePosition
+				case *corev1.CaveatDefinition:
+					pos = def.SourcePosition
+				}
+				return nil, nil, &SchemaError{Position: sourcePosition(source, pos), Err: fmt.Errorf("definition %s is already defined in %s", def.GetName(), prev)}
+			}
+			definedIn[def.GetName()] = source
+		}
+		merged.ObjectDefinitions = append(merged.ObjectDefinitions, compiled.ObjectDefinitions...)
+		merged.CaveatDefinitions = append(merged.CaveatDefinitions, compiled.CaveatDefinitions...)
+		merged.OrderedDefinitions = append(merged.OrderedDefinitions, compiled.OrderedDefinitions...)
+	}
+	return merged, definedIn, nil
+}
+
+// Compiles the schema text, converting compile errors into a SchemaError
+func compileSchema(source, schematxt string) (*compiler.CompiledSchema, error) {
+	compiled, err := compiler.Compile(compiler.InputSchema{Source: input.Source(source), SchemaString: schematxt}, compiler.ObjectTypePrefix(""))
+	if err != nil {
+		return nil, compileError(err)
+	}
+	return compiled, nil
+}
+
+// Whether the file is a zed validation file (i.e. schema.zed.yaml) rather than a raw schema
+func isValidationFile(file string) bool {
+	ext := filepath.Ext(file)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// Reads the schema text from a schema file, returning the name of the file the schema is from. The schema of a zed
+// validation file is read from its schema key, or from the file its schemaFile key points at.
+func readSchemaFile(file string) (string, string, error) {
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return "", "", fmt.Errorf("error reading schema file: %w", err)
+	}
+	if !isValidationFile(file) {
+		return file, string(contents), nil
+	}
+	validationFile := struct {
+		Schema     yaml.Node `yaml:"schema"`
+		SchemaFile string    `yaml:"schemaFile"`
+	}{}
+	if err := yaml.Unmarshal(contents, &validationFile); err != nil {
+		return "", "", fmt.Errorf("error reading validation file %s: %w", file, err)
+	}
+	switch {
+	case validationFile.Schema.Kind != 0 && validationFile.SchemaFile != "":
+		return "", "", fmt.Errorf("validation file %s has both a schema and a schemaFile", file)
+	case validationFile.SchemaFile != "":
+		schemaFile := filepath.Clean(validationFile.SchemaFile)
+		if !filepath.IsAbs(schemaFile) {
+			schemaFile = filepath.Join(filepath.Dir(file), schemaFile)
+		}
+		if isValidationFile(schemaFile) {
+			return "", "", fmt.Errorf("validation file %s has a schemaFile that is not a schema: %s", file, validationFile.SchemaFile)
+		}
+		return readSchemaFile(schemaFile)
+	case validationFile.Schema.Kind != yaml.ScalarNode:
+		return "", "", fmt.Errorf("validation file %s has no schema", file)
+	}
+	// pad the schema so that compile errors point at the line in the validation file
+	line := validationFile.Schema.Line - 1
+	if validationFile.Schema.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		line++
+	}
+	return file, strings.Repeat("\n", line) + validationFile.Schema.Value, nil
+}
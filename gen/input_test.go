@@ -0,0 +1,193 @@
+package gen
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeSchemaFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestResolveSchemaFiles(t *testing.T) {
+	dir := writeSchemaFiles(t, map[string]string{
+		"user.zed":           "definition user {}",
+		"docs/document.zed":  "definition document {}",
+		"docs/folder.zed":    "definition folder {}",
+		"docs/README.md":     "not a schema",
+		"legacy/schema.text": "definition legacy {}",
+	})
+	tests := []struct {
+		name   string
+		inputs []string
+		files  []string
+		err    string
+	}{
+		{
+			name:   "single file",
+			inputs: []string{"legacy/schema.text"},
+			files:  []string{"legacy/schema.text"},
+		},
+		{
+			name:   "directory",
+			inputs: []string{"."},
+			files:  []string{"docs/document.zed", "docs/folder.zed", "user.zed"},
+		},
+		{
+			name:   "glob and repeated inputs",
+			inputs: []string{"user.zed", "docs/*.zed", "docs/folder.zed"},
+			files:  []string{"user.zed", "docs/document.zed", "docs/folder.zed"},
+		},
+		{
+			name:   "missing file",
+			inputs: []string{"missing.zed"},
+			err:    `no schema files found for "%s/missing.zed"`,
+		},
+		{
+			name:   "directory without schema files",
+			inputs: []string{"legacy"},
+			err:    `no .zed files found in directory "%s/legacy"`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			inputs := make([]string, len(tc.inputs))
+			for i, in := range tc.inputs {
+				inputs[i] = filepath.Join(dir, in)
+			}
+			files, err := ResolveSchemaFiles(inputs)
+			if tc.err != "" {
+				assert.EqualError(t, err, strings.ReplaceAll(tc.err, "%s", dir))
+				return
+			}
+			assert.NoError(t, err)
+			expected := make([]string, len(tc.files))
+			for i, file := range tc.files {
+				expected[i] = filepath.Join(dir, file)
+			}
+			assert.Equal(t, expected, files)
+		})
+	}
+}
+
+func TestCompileSchemaFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		inputs   []string // defaults to the directory of files
+		validate func(t *testing.T, schema Schema)
+		err      string
+	}{
+		{
+			name: "definitions across files",
+			files: map[string]string{
+				"a_user.zed":     "definition user {}\ncaveat on_weekday(day int) { day < 6 }",
+				"b_document.zed": "definition document {\n relation reader: user with on_weekday\n permission view = reader\n}",
+			},
+			validate: func(t *testing.T, schema Schema) {
+				assert.Len(t, schema.Resources, 2)
+				assert.Equal(t, "user", schema.Resources["document"].PermissionSubjectType)
+				assert.Equal(t, "on_weekday", schema.Resources["document"].Relations["reader"].RelationRefs[0].Caveat)
+				assert.Contains(t, schema.Caveats, "on_weekday")
+			},
+		},
+		{
+			name: "compile errors point at the file",
+			files: map[string]string{
+				"a_user.zed":     "definition user {}",
+				"b_document.zed": "definition document {\n relation reader: user |\n}",
+			},
+			err: "%s/b_document.zed:3:1: Expected identifier, found token TokenTypeRightBrace",
+		},
+		{
+			name: "duplicate definitions",
+			files: map[string]string{
+				"a_user.zed": "definition user {}",
+				"b_user.zed": "definition user {}",
+			},
+			err: "%s/b_user.zed: definition user is already defined in %s/a_user.zed",
+		},
+		{
+			name: "validation file with a schema",
+			files: map[string]string{
+				"schema.zed.yaml": "---\nschema: |-\n  definition user {}\n  definition document {\n    relation reader: user\n  }\nrelationships: |-\n  document:readme#reader@user:alice\n",
+			},
+			inputs: []string{"schema.zed.yaml"},
+			validate: func(t *testing.T, schema Schema) {
+				assert.Len(t, schema.Resources, 2)
+				assert.Equal(t, "user", schema.Resources["document"].RelationSubjectType)
+			},
+		},
+		{
+			name: "validation file with a schemaFile",
+			files: map[string]string{
+				"validation/schema.zed.yaml": "schemaFile: ../schema/schema.zed\nassertions:\n  assertTrue: []\n",
+				"schema/schema.zed":          "definition user {}",
+			},
+			inputs: []string{"validation/schema.zed.yaml"},
+			validate: func(t *testing.T, schema Schema) {
+				assert.Len(t, schema.Resources, 1)
+			},
+		},
+		{
+			name: "validation file compile errors point at the validation file",
+			files: map[string]string{
+				"schema.zed.yaml": "---\nschema: |-\n  definition user {}\n  definition document {\n    relation reader: user |\n  }\n",
+			},
+			inputs: []string{"schema.zed.yaml"},
+			err:    "%s/schema.zed.yaml:6:1: Expected identifier, found token TokenTypeRightBrace",
+		},
+		{
+			name: "validation file and the schema file it points at",
+			files: map[string]string{
+				"schema/schema.zed.yaml": "schemaFile: ./schema.zed\n",
+				"schema/schema.zed":      "definition user {}\ndefinition document {\n relation reader: user\n}",
+			},
+			inputs: []string{"schema/*"},
+			validate: func(t *testing.T, schema Schema) {
+				assert.Len(t, schema.Resources, 2)
+			},
+		},
+		{
+			name: "validation file without a schema",
+			files: map[string]string{
+				"schema.zed.yaml": "relationships: ''\n",
+			},
+			inputs: []string{"schema.zed.yaml"},
+			err:    "validation file %s/schema.zed.yaml has no schema",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := writeSchemaFiles(t, tc.files)
+			inputs := []string{dir}
+			if tc.inputs != nil {
+				inputs = make([]string, len(tc.inputs))
+				for i, in := range tc.inputs {
+					inputs[i] = filepath.Join(dir, in)
+				}
+			}
+			files, err := ResolveSchemaFiles(inputs)
+			assert.NoError(t, err)
+			compiled, err := CompileSchemaFiles(files)
+			if tc.err != "" {
+				assert.EqualError(t, err, strings.ReplaceAll(tc.err, "%s", dir))
+				return
+			}
+			assert.NoError(t, err)
+			schema, err := BuildSchema(compiled)
+			assert.NoError(t, err)
+			tc.validate(t, schema)
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package gen
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const directivePrefix = "//spicegen:"
+
+// A single spicegen directive from a doc comment, i.e. //spicegen:subject_type=team#member
+type directive struct {
+	raw      string
+	key      string
+	value    string
+	hasValue bool
+}
+
+// MetatagError is returned for unknown or malformed spicegen metatags. Relation is empty for metatags on a definition.
+type MetatagError struct {
+	Definition string
+	Relation   string
+	Metatag    string
+	Reason     string
+}
+
+func (e MetatagError) Error() string {
+	target := e.Definition
+	if e.Relation != "" {
+		target = fmt.Sprintf("%s#%s", e.Definition, e.Relation)
+	}
+	return fmt.Sprintf("invalid metatag %q on %s: %s", e.Metatag, target, e.Reason)
+}
+
+type metatagScope int
+
+const (
+	relationScope metatagScope = 1 << iota
+	definitionScope
+)
+
+type metatagSpec struct {
+	value         bool // whether the metatag requires a value
+	optionalValue bool // whether the metatag may be given with or without a value
+	repeatable    bool // whether the metatag may be given more than once
+	scope         metatagScope
+	validate      func(value string) error
+}
+
+var (
+	directiveRegexp   = regexp.MustCompile(`//spicegen:\w*(="(?:[^"\\]|\\.)*"|=\S*)?`)
+	identifierRegexp  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	subjectTypeRegexp = regexp.MustCompile(`^[a-z][a-z0-9_/]*(#([a-z][a-z0-9_]*|\.\.\.))?$`)
+)
+
+var metatagSpecs = map[string]metatagSpec{
+	// Override subject type inference. This type does not have to exist in the schema!
+	"subject_type": {value: true, repeatable: true, scope: relationScope, validate: func(value string) error {
+		if !subjectTypeRegexp.MatchString(value) {
+			return fmt.Errorf("expected a subject type like user or team#member, got %q", value)
+		}
+		return nil
+	}},
+	// Rename public type but use value for spicedb
+	"rename": {value: true, scope: relationScope, validate: validateIdentifier},
+	// Skip generating the relation or the entire definition
+	"ignore": {scope: relationScope | definitionScope},
+	// Mark the generated code as deprecated, with an optional reason
+	"deprecated": {optionalValue: true, scope: relationScope | definitionScope},
+	// Use the value instead of the schema doc comment in the Go doc comment
+	"doc": {value: true, scope: relationScope | definitionScope},
+	// Generate an additional deprecated constant with the value as name, i.e. the name before a rename
+	"alias": {value: true, repeatable: true, scope: relationScope, validate: validateIdentifier},
+}
+
+func validateIdentifier(value string) error {
+	if !identifierRegexp.MatchString(value) {
+		return fmt.Errorf("expected an identifier, got %q", value)
+	}
+	return nil
+}
+
+// Tokenizes every spicegen directive in the comment. Directives can be mixed with prose and each other, and values are
+// either a bare word ending at whitespace (or the end of the comment) or a double quoted Go string.
+func tokenizeDirectives(comment string) ([]directive, []MetatagError) {
+	directives := make([]directive, 0)
+	errs := make([]MetatagError, 0)
+	rest := comment
+	for {
+		idx := strings.Index(rest, directivePrefix)
+		if idx == -1 {
+			return directives, errs
+		}
+		rest = rest[idx+len(directivePrefix):]
+		d := directive{}
+		keyEnd := strings.IndexFunc(rest, func(r rune) bool { return !(r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)) })
+		if keyEnd == -1 {
+			keyEnd = len(rest)
+		}
+		d.key = rest[:keyEnd]
+		rest = rest[keyEnd:]
+		if strings.HasPrefix(rest, "=") {
+			d.hasValue = true
+			rest = rest[1:]
+			var err error
+			d.value, rest, err = scanValue(rest)
+			if err != nil {
+				errs = append(errs, MetatagError{Metatag: directivePrefix + d.key + "=", Reason: err.Error()})
+				continue
+			}
+		}
+		d.raw = directivePrefix + d.key
+		if d.hasValue {
+			d.raw += "=" + d.value
+		}
+		if d.key == "" {
+			errs = append(errs, MetatagError{Metatag: d.raw, Reason: "missing metatag name"})
+			continue
+		}
+		if rest != "" && !startsWithSeparator(rest) {
+			errs = append(errs, MetatagError{Metatag: d.raw, Reason: fmt.Sprintf("unexpected %q after metatag", firstWord(rest))})
+			rest = rest[len(firstWord(rest)):]
+			continue
+		}
+		directives = append(directives, d)
+	}
+}
+
+// Scans a directive value, returning the value and the remainder of the comment.
+func scanValue(s string) (string, string, error) {
+	if strings.HasPrefix(s, `"`) {
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				value, err := strconv.Unquote(s[:i+1])
+				if err != nil {
+					return "", s[i+1:], fmt.Errorf("invalid quoted value: %w", err)
+				}
+				return value, s[i+1:], nil
+			}
+		}
+		return "", "", fmt.Errorf("unterminated quoted value")
+	}
+	value := firstWord(s)
+	if value == "" {
+		return "", s, fmt.Errorf("missing metatag value")
+	}
+	return value, s[len(value):], nil
+}
+
+// Returns the leading run of s up to whitespace or the end of a block comment
+func firstWord(s string) string {
+	end := strings.IndexFunc(s, unicode.IsSpace)
+	if end == -1 {
+		end = len(s)
+	}
+	if idx := strings.Index(s[:end], "*/"); idx != -1 {
+		end = idx
+	}
+	return s[:end]
+}
+
+func startsWithSeparator(s string) bool {
+	return strings.HasPrefix(s, "*/") || unicode.IsSpace([]rune(s)[0])
+}
+
+// Returns the prose of the doc comments, without comment markers or spicegen directives.
+func commentText(comments []string) string {
+	lines := make([]string, 0)
+	for _, comment := range comments {
+		comment = directiveRegexp.ReplaceAllString(comment, "")
+		comment = strings.TrimPrefix(comment, "/**")
+		comment = strings.TrimPrefix(comment, "/*")
+		comment = strings.TrimSuffix(comment, "*/")
+		for _, line := range strings.Split(comment, "\n") {
+			line = strings.TrimSpace(line)
+			line = strings.TrimPrefix(line, "//")
+			line = strings.TrimPrefix(line, "*")
+			lines = append(lines, strings.TrimSpace(line))
+		}
+	}
+	// trim blank lines and collapse repeated blank lines
+	res := make([]string, 0)
+	for _, line := range lines {
+		if line == "" && (len(res) == 0 || res[len(res)-1] == "") {
+			continue
+		}
+		res = append(res, line)
+	}
+	return strings.TrimSpace(strings.Join(res, "\n"))
+}
+
+// Parses and validates the directives in the comments of a definition or relation against the known metatags.
+func parseDirectives(definition, relation string, scope metatagScope, comments []string) ([]directive, error) {
+	result := make([]directive, 0)
+	seen := map[string]bool{}
+	for _, comment := range comments {
+		directives, errs := tokenizeDirectives(comment)
+		if len(errs) > 0 {
+			errs[0].Definition, errs[0].Relation = definition, relation
+			return nil, errs[0]
+		}
+		for _, d := range directives {
+			fail := func(reason string) error {
+				return MetatagError{Definition: definition, Relation: relation, Metatag: d.raw, Reason: reason}
+			}
+			spec, ok := metatagSpecs[d.key]
+			switch {
+			case !ok:
+				return nil, fail("unknown metatag")
+			case spec.scope&scope == 0 && scope == definitionScope:
+				return nil, fail("not supported on a definition")
+			case spec.scope&scope == 0:
+				return nil, fail("not supported on a relation or permission")
+			case spec.value && !d.hasValue:
+				return nil, fail("missing metatag value")
+			case !spec.value && !spec.optionalValue && d.hasValue:
+				return nil, fail("metatag does not take a value")
+			case seen[d.key] && !spec.repeatable:
+				return nil, fail("metatag given more than once")
+			}
+			if spec.validate != nil {
+				if err := spec.validate(d.value); err != nil {
+					return nil, fail(err.Error())
+				}
+			}
+			seen[d.key] = true
+			result = append(result, d)
+		}
+	}
+	return result, nil
+}
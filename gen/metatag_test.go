@@ -0,0 +1,137 @@
+package gen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDirectives(t *testing.T) {
+	tests := []struct {
+		name     string
+		comments []string
+		scope    metatagScope
+		expected []directive
+		err      string
+	}{
+		{
+			name:     "single metatag",
+			comments: []string{"/** //spicegen:subject_type=user */"},
+			scope:    relationScope,
+			expected: []directive{{raw: "//spicegen:subject_type=user", key: "subject_type", value: "user", hasValue: true}},
+		},
+		{
+			name:     "metatag with prose and value containing separators",
+			comments: []string{"/**\nreader indicates that the team can read //spicegen:subject_type=team#member\n*/"},
+			scope:    relationScope,
+			expected: []directive{{raw: "//spicegen:subject_type=team#member", key: "subject_type", value: "team#member", hasValue: true}},
+		},
+		{
+			name:     "multiple metatags on one line",
+			comments: []string{"// //spicegen:subject_type=user //spicegen:subject_type=team#member //spicegen:rename=viewer"},
+			scope:    relationScope,
+			expected: []directive{
+				{raw: "//spicegen:subject_type=user", key: "subject_type", value: "user", hasValue: true},
+				{raw: "//spicegen:subject_type=team#member", key: "subject_type", value: "team#member", hasValue: true},
+				{raw: "//spicegen:rename=viewer", key: "rename", value: "viewer", hasValue: true},
+			},
+		},
+		{
+			name:     "quoted value",
+			comments: []string{`/** //spicegen:rename="viewer"*/`},
+			scope:    relationScope,
+			expected: []directive{{raw: "//spicegen:rename=viewer", key: "rename", value: "viewer", hasValue: true}},
+		},
+		{
+			name:     "unknown metatag",
+			comments: []string{"/** //spicegen:subjecttype=user */"},
+			scope:    relationScope,
+			err:      `invalid metatag "//spicegen:subjecttype=user" on document#reader: unknown metatag`,
+		},
+		{
+			name:     "missing value",
+			comments: []string{"/** //spicegen:rename */"},
+			scope:    relationScope,
+			err:      `invalid metatag "//spicegen:rename" on document#reader: missing metatag value`,
+		},
+		{
+			name:     "empty value",
+			comments: []string{"/** //spicegen:rename= */"},
+			scope:    relationScope,
+			err:      `invalid metatag "//spicegen:rename=" on document#reader: missing metatag value`,
+		},
+		{
+			name:     "unterminated quote",
+			comments: []string{`/** //spicegen:rename="viewer */`},
+			scope:    relationScope,
+			err:      `invalid metatag "//spicegen:rename=" on document#reader: unterminated quoted value`,
+		},
+		{
+			name:     "invalid subject type",
+			comments: []string{"/** //spicegen:subject_type=team#member#foo */"},
+			scope:    relationScope,
+			err:      `invalid metatag "//spicegen:subject_type=team#member#foo" on document#reader: expected a subject type like user or team#member, got "team#member#foo"`,
+		},
+		{
+			name:     "repeated metatag",
+			comments: []string{"/** //spicegen:rename=a */", "/** //spicegen:rename=b */"},
+			scope:    relationScope,
+			err:      `invalid metatag "//spicegen:rename=b" on document#reader: metatag given more than once`,
+		},
+		{
+			name:     "relation metatag on a definition",
+			comments: []string{"/** //spicegen:rename=doc */"},
+			scope:    definitionScope,
+			err:      `invalid metatag "//spicegen:rename=doc" on document: not supported on a definition`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			relation := "reader"
+			if tc.scope == definitionScope {
+				relation = ""
+			}
+			directives, err := parseDirectives("document", relation, tc.scope, tc.comments)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, directives)
+		})
+	}
+}
+
+func TestCommentText(t *testing.T) {
+	tests := []struct {
+		name     string
+		comments []string
+		expected string
+	}{
+		{
+			name:     "block comment",
+			comments: []string{"/** view indicates whether the user can view the document */"},
+			expected: "view indicates whether the user can view the document",
+		},
+		{
+			name:     "multiline block comment with metatags",
+			comments: []string{"/**\n* reader can read\n* the document //spicegen:rename=\"viewer\"\n*\n* more prose\n*/", "/** //spicegen:subject_type=user */"},
+			expected: "reader can read\nthe document\n\nmore prose",
+		},
+		{
+			name:     "line comments",
+			comments: []string{"// first line", "// second line"},
+			expected: "first line\nsecond line",
+		},
+		{
+			name:     "only metatags",
+			comments: []string{"/** //spicegen:subject_type=user */"},
+			expected: "",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, commentText(tc.comments))
+		})
+	}
+}
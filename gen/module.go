@@ -0,0 +1,41 @@
+package gen
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"golang.org/x/mod/modfile"
+)
+
+// InferImportPath returns the import path of the output directory, found from the module path in the nearest go.mod
+// in or above it. The directory does not need to exist yet.
+func InferImportPath(outputDir string) (string, error) {
+	abs, err := filepath.Abs(outputDir)
+	if err != nil {
+		return "", err
+	}
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		content, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("error reading go.mod: %w", err)
+		}
+		if err == nil {
+			module := modfile.ModulePath(content)
+			if module == "" {
+				return "", fmt.Errorf("no module directive found in %s", filepath.Join(dir, "go.mod"))
+			}
+			rel, err := filepath.Rel(dir, abs)
+			if err != nil {
+				return "", err
+			}
+			return path.Join(module, filepath.ToSlash(rel)), nil
+		}
+		if filepath.Dir(dir) == dir {
+			return "", fmt.Errorf("no go.mod found in %s or any parent directory, set the import path", abs)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package gen
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInferImportPath(t *testing.T) {
+	dir := writeSchemaFiles(t, map[string]string{
+		"go.mod":             "// the app\nmodule github.com/ben-mays/app // trailing comment\n\ngo 1.21\n",
+		"tools/go.mod":       "module \"github.com/ben-mays/app/tools\"\n",
+		"broken/go.mod":      "go 1.21\n",
+		"internal/README.md": "not a module",
+	})
+	tests := []struct {
+		name     string
+		output   string
+		expected string
+		err      string
+	}{
+		{
+			name:     "module root",
+			output:   ".",
+			expected: "github.com/ben-mays/app",
+		},
+		{
+			name:     "missing output directory",
+			output:   "internal/authz",
+			expected: "github.com/ben-mays/app/internal/authz",
+		},
+		{
+			name:     "nested module",
+			output:   "tools/authz",
+			expected: "github.com/ben-mays/app/tools/authz",
+		},
+		{
+			name:   "no module directive",
+			output: "broken/authz",
+			err:    "no module directive found in %s/broken/go.mod",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			importPath, err := InferImportPath(filepath.Join(dir, tc.output))
+			if tc.err != "" {
+				assert.EqualError(t, err, strings.ReplaceAll(tc.err, "%s", dir))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, importPath)
+		})
+	}
+}
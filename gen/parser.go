@@ -0,0 +1,580 @@
+package gen
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/authzed/spicedb/pkg/namespace"
+	corev1 "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/iancoleman/strcase"
+	"golang.org/x/exp/maps"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+type Schema struct {
+	Resources      map[string]Resource
+	ResourcesArray []Resource // sorted by name
+	Caveats        map[string]Caveat
+	CaveatsArray   []Caveat // sorted by name
+}
+
+type Caveat struct {
+	Name      string
+	Args      map[string]string // arg name to arg type
+	ArgsArray []CaveatArg       // sorted by arg name
+}
+
+type CaveatArg struct {
+	Name string
+	Type CaveatType
+}
+
+// CaveatType is a caveat parameter type, i.e. list<ipaddress> is {Name: "list", Children: [{Name: "ipaddress"}]}
+type CaveatType struct {
+	Name     string
+	Children []CaveatType
+}
+
+func (t CaveatType) String() string {
+	if len(t.Children) == 0 {
+		return t.Name
+	}
+	children := make([]string, len(t.Children))
+	for i, child := range t.Children {
+		children[i] = child.String()
+	}
+	return fmt.Sprintf("%s<%s>", t.Name, strings.Join(children, ", "))
+}
+
+type RelationRef struct {
+	ResourceType string // i.e. team#members -> team
+	Relation     string // i.e. team#members -> member
+	Caveat       string // key to caveat in schema
+	Tupleset     string // only set for arrows, i.e. parent->view -> parent. ResourceType is the resource holding the tupleset.
+	Wildcard     bool   // i.e. user:*
+}
+
+type Relation struct {
+	Name       string
+	OutputName string
+	Kind       string
+	Doc        string   // from the schema doc comment or the doc metatag
+	Expression string   // the rewrite of a permission, i.e. reader + writer + docorg->view_all_documents
+	Rewrite    *Expr    // the expression tree of a permission, nil for relations
+	Deprecated string   // deprecation notice, empty if not deprecated
+	Aliases    []string // additional output names, i.e. the names before a rename
+	Ignored    bool
+	// ResourceTypes to enforce, mapped to the required subject relation. For relations these are the subject types that
+	// can be written directly, for permissions these are the concrete subject types that can hold the permission.
+	AllowedSubjectTypes         map[string]string
+	OverrideAllowedSubjectTypes map[string]string
+	// Sorted concrete subject types that can hold the relation or permission, following subject relations, i.e. user
+	// for a relation of team#member. Used to validate the subject of a check.
+	HolderSubjectTypes []string
+	// Used for resolving allowed subject types if not given in a metatag
+	RelationRefs []RelationRef
+	// Sorted resource types when a relation accepts more than one, used to generate a sealed subject interface
+	SubjectUnion []string
+}
+
+type Resource struct {
+	Name             string // the definition name, including any prefix, i.e. billing/invoice
+	GoName           string // the Go identifier for the definition, i.e. BillingInvoice
+	PackageName      string // the name of the permissions package, i.e. invoice
+	PackageAlias     string // the name the permissions package is imported as, i.e. billing_invoice
+	Doc              string // from the schema doc comment or the doc metatag
+	Deprecated       string // deprecation notice, empty if not deprecated
+	Ignored          bool
+	Permissions      map[string]Relation
+	PermissionsArray []Relation
+	Relations        map[string]Relation
+	RelationsArray   []Relation
+
+	// Either a specific resource type or "Resource"
+	PermissionSubjectType string
+	// Either a specific resource type or "Resource"
+	RelationSubjectType string
+	// Sorted resource types when the relations accept more than one, used to generate a sealed subject interface
+	RelationSubjectUnion []string
+
+	// Set by the go emitter for the layout of the enums
+	EnumImportPath string // the import path of the permissions package, empty in the flat layout
+	EnumQualifier  string // qualifies the enum types in the client package, i.e. billing_invoice., empty in the flat layout
+	ConstPrefix    string // prefixes the enum constants, i.e. BillingInvoice in the flat layout, empty otherwise
+}
+
+// Returns the subject in schema syntax, i.e. user, user:*, team#member or user with ip_allowed
+func (r RelationRef) String() string {
+	res := r.ResourceType
+	switch {
+	case r.Wildcard:
+		res += ":*"
+	case r.Relation != "" && r.Relation != "...":
+		res += "#" + r.Relation
+	}
+	if r.Caveat != "" {
+		res += " with " + r.Caveat
+	}
+	return res
+}
+
+// Returns the subject types allowed for the relation, preferring the metatag override to the inferred types.
+func (r Relation) SubjectTypes() map[string]string {
+	if r.OverrideAllowedSubjectTypes != nil {
+		return r.OverrideAllowedSubjectTypes
+	}
+	return r.AllowedSubjectTypes
+}
+
+func set(arr ...string) []string {
+	res := map[string]bool{}
+	for _, x := range arr {
+		res[x] = true
+	}
+	return maps.Keys(res)
+}
+
+func BuildSchema(compiledSchema *compiler.CompiledSchema) (Schema, error) {
+	return buildSchema(compiledSchema, filter{})
+}
+
+func buildSchema(compiledSchema *compiler.CompiledSchema, f filter) (Schema, error) {
+	state := map[string]Resource{}
+	// Walk all objects and write their permissions/relations to state. Note, we don't resolve relation types here,
+	// so a relation may have a non-resource type. We need to resolve that later in a second pass.
+	for _, sd := range compiledSchema.ObjectDefinitions {
+		metatag, err := parseMetatags(sd.Name, "", sd.Metadata)
+		if err != nil {
+			return Schema{}, &SchemaError{Position: sourcePosition("", sd.SourcePosition), Err: err}
+		}
+		permissions := make(map[string]Relation, 0)
+		relations := make(map[string]Relation, 0)
+		for _, rel := range sd.Relation {
+			relation, err := handleRelation(sd.Name, rel)
+			if err != nil {
+				return Schema{}, &SchemaError{Position: sourcePosition("", rel.SourcePosition), Err: err}
+			}
+			if relation.Kind == "permission" {
+				permissions[relation.Name] = relation
+			} else {
+				relations[relation.Name] = relation
+			}
+		}
+		state[sd.Name] = Resource{
+			Name:         sd.Name,
+			GoName:       goName(sd.Name),
+			PackageName:  path.Base(sd.Name),
+			PackageAlias: strings.ReplaceAll(sd.Name, "/", "_"),
+			Doc:          metatag.doc,
+			Deprecated:   metatag.deprecated,
+			Ignored:      metatag.ignore,
+			Permissions:  permissions,
+			Relations:    relations,
+			// default to resource which is the abstract baseclass (i.e. wildcard)
+			PermissionSubjectType: "resource",
+			RelationSubjectType:   "resource",
+		}
+	}
+	resolveSubjectTypes(state)
+	if err := f.apply(state); err != nil {
+		return Schema{}, err
+	}
+	// ignored definitions and relations take part in resolving subject types, but are not generated
+	for name, rsc := range state {
+		if rsc.Ignored {
+			delete(state, name)
+			continue
+		}
+		for _, rels := range []map[string]Relation{rsc.Relations, rsc.Permissions} {
+			for relName, rel := range rels {
+				if rel.Ignored {
+					delete(rels, relName)
+				}
+			}
+		}
+	}
+	resolveResourceSubjectTypes(state)
+	caveats := map[string]Caveat{}
+	for _, cd := range compiledSchema.CaveatDefinitions {
+		caveats[cd.Name] = handleCaveat(cd)
+	}
+	return Schema{Resources: state, Caveats: caveats}, nil
+}
+
+// Returns the Go identifier for a definition or caveat name. Prefixes are kept, i.e. billing/invoice -> BillingInvoice
+func goName(name string) string {
+	return strcase.ToCamel(strings.ReplaceAll(name, "/", "_"))
+}
+
+// StripObjectPrefix removes the object prefix from every definition and caveat in the schema, i.e. tenant1/document ->
+// document, so the generated code is independent of the tenant. Every definition and caveat must have the prefix.
+func StripObjectPrefix(compiledSchema *compiler.CompiledSchema, prefix string) error {
+	strip := func(kind, name string) (string, error) {
+		stripped, ok := strings.CutPrefix(name, prefix+"/")
+		if !ok {
+			return "", fmt.Errorf("%s %s does not have the object prefix %s", kind, name, prefix)
+		}
+		return stripped, nil
+	}
+	var err error
+	for _, sd := range compiledSchema.ObjectDefinitions {
+		if sd.Name, err = strip("definition", sd.Name); err != nil {
+			return err
+		}
+		for _, rel := range sd.Relation {
+			if rel.TypeInformation == nil {
+				continue
+			}
+			for _, allowed := range rel.TypeInformation.AllowedDirectRelations {
+				if allowed.Namespace, err = strip("definition", allowed.Namespace); err != nil {
+					return err
+				}
+				if allowed.RequiredCaveat != nil {
+					if allowed.RequiredCaveat.CaveatName, err = strip("caveat", allowed.RequiredCaveat.CaveatName); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	for _, cd := range compiledSchema.CaveatDefinitions {
+		if cd.Name, err = strip("caveat", cd.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Second pass over the schema, resolving the RelationRefs of every relation and the Rewrite of every permission into the
+// concrete subject types that can hold them. Computed usersets, arrows and subject relations (i.e. team#member) are followed until
+// no new types are found, which terminates on recursive schemas since the sets only grow.
+func resolveSubjectTypes(state map[string]Resource) {
+	key := func(resourceType, relation string) string { return resourceType + "#" + relation }
+	lookup := func(resourceType, relation string) (Relation, bool) {
+		rsc, ok := state[resourceType]
+		if !ok {
+			return Relation{}, false
+		}
+		if rel, ok := rsc.Relations[relation]; ok {
+			return rel, true
+		}
+		rel, ok := rsc.Permissions[relation]
+		return rel, ok
+	}
+	holders := map[string]map[string]bool{}
+	add := func(k string, subjectTypes ...string) bool {
+		if holders[k] == nil {
+			holders[k] = map[string]bool{}
+		}
+		changed := false
+		for _, subjectType := range subjectTypes {
+			if !holders[k][subjectType] {
+				holders[k][subjectType] = true
+				changed = true
+			}
+		}
+		return changed
+	}
+	// resolves the current holders of a single ref
+	resolveRef := func(ref RelationRef) map[string]bool {
+		res := map[string]bool{}
+		switch {
+		case ref.Tupleset != "":
+			// follow the arrow to every resource the tupleset can point at
+			tupleset, ok := lookup(ref.ResourceType, ref.Tupleset)
+			if !ok {
+				return res
+			}
+			for _, target := range tupleset.RelationRefs {
+				if _, ok := lookup(target.ResourceType, ref.Relation); ok {
+					for subjectType := range holders[key(target.ResourceType, ref.Relation)] {
+						res[subjectType] = true
+					}
+				}
+			}
+		case ref.Relation == "...":
+			res[ref.ResourceType] = true
+		default:
+			for subjectType := range holders[key(ref.ResourceType, ref.Relation)] {
+				res[subjectType] = true
+			}
+		}
+		return res
+	}
+	// evaluates a permission expression over the current holders. An intersection can only be held by types holding
+	// every operand, and an exclusion only by types holding the base set, since subtracting never grants anything.
+	var eval func(resourceType string, expr *Expr) map[string]bool
+	eval = func(resourceType string, expr *Expr) map[string]bool {
+		switch expr.Kind {
+		case ComputedUsersetExpr, ArrowExpr:
+			return resolveRef(RelationRef{ResourceType: resourceType, Relation: expr.Relation, Tupleset: expr.Tupleset})
+		case UnionExpr:
+			res := map[string]bool{}
+			for _, child := range expr.Children {
+				for subjectType := range eval(resourceType, child) {
+					res[subjectType] = true
+				}
+			}
+			return res
+		case IntersectionExpr:
+			var res map[string]bool
+			for _, child := range expr.Children {
+				types := eval(resourceType, child)
+				if res == nil {
+					res = types
+					continue
+				}
+				for subjectType := range res {
+					if !types[subjectType] {
+						delete(res, subjectType)
+					}
+				}
+			}
+			return res
+		case ExclusionExpr:
+			if len(expr.Children) > 0 {
+				return eval(resourceType, expr.Children[0])
+			}
+		}
+		return nil
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, rsc := range state {
+			for _, rels := range []map[string]Relation{rsc.Relations, rsc.Permissions} {
+				for _, rel := range rels {
+					k := key(rsc.Name, rel.Name)
+					if rel.OverrideAllowedSubjectTypes != nil {
+						changed = add(k, maps.Keys(rel.OverrideAllowedSubjectTypes)...) || changed
+						continue
+					}
+					if rel.Rewrite != nil {
+						changed = add(k, maps.Keys(eval(rsc.Name, rel.Rewrite))...) || changed
+						continue
+					}
+					for _, ref := range rel.RelationRefs {
+						changed = add(k, maps.Keys(resolveRef(ref))...) || changed
+					}
+				}
+			}
+		}
+	}
+
+	for _, rsc := range state {
+		for relName, rel := range rsc.Permissions {
+			rel.AllowedSubjectTypes = map[string]string{}
+			for subjectType := range holders[key(rsc.Name, rel.Name)] {
+				rel.AllowedSubjectTypes[subjectType] = "..."
+			}
+			rel.HolderSubjectTypes = maps.Keys(holders[key(rsc.Name, rel.Name)])
+			sort.Strings(rel.HolderSubjectTypes)
+			rsc.Permissions[relName] = rel
+		}
+		for relName, rel := range rsc.Relations {
+			rel.HolderSubjectTypes = maps.Keys(holders[key(rsc.Name, rel.Name)])
+			sort.Strings(rel.HolderSubjectTypes)
+			rel.AllowedSubjectTypes = map[string]string{}
+			for _, ref := range rel.RelationRefs {
+				// prefer the plain object when a type is allowed both with and without a subject relation
+				if cur, ok := rel.AllowedSubjectTypes[ref.ResourceType]; !ok || cur != "..." {
+					rel.AllowedSubjectTypes[ref.ResourceType] = ref.Relation
+				}
+			}
+			rsc.Relations[relName] = rel
+		}
+	}
+}
+
+// Picks the subject types used in each resource's API from the resolved subject types of its relations and permissions.
+// This runs after ignored definitions are removed, so only generated resources are used as concrete subject types.
+func resolveResourceSubjectTypes(state map[string]Resource) {
+	for name, rsc := range state {
+		permissionSubjectTypes := map[string]bool{}
+		for _, rel := range rsc.Permissions {
+			for subjectType := range rel.SubjectTypes() {
+				permissionSubjectTypes[subjectType] = true
+			}
+		}
+		relationSubjectTypes := map[string]bool{}
+		for relName, rel := range rsc.Relations {
+			for subjectType := range rel.SubjectTypes() {
+				relationSubjectTypes[subjectType] = true
+			}
+			rel.SubjectUnion = resolveSubjectUnion(state, maps.Keys(rel.SubjectTypes()))
+			rsc.Relations[relName] = rel
+		}
+		rsc.PermissionSubjectType = singleSubjectType(state, permissionSubjectTypes)
+		rsc.RelationSubjectType = singleSubjectType(state, relationSubjectTypes)
+		rsc.RelationSubjectUnion = resolveSubjectUnion(state, maps.Keys(relationSubjectTypes))
+		state[name] = rsc
+	}
+}
+
+// Returns the sorted subject types if there are several and all of them are resources in the schema. Go has no union
+// types, so these are generated as a sealed interface implemented by each of the resources.
+func resolveSubjectUnion(state map[string]Resource, subjectTypes []string) []string {
+	if len(subjectTypes) < 2 {
+		return nil
+	}
+	for _, subjectType := range subjectTypes {
+		if _, ok := state[subjectType]; !ok {
+			return nil
+		}
+	}
+	sort.Strings(subjectTypes)
+	return subjectTypes
+}
+
+// Returns the subject type if there is exactly one and it is a resource in the schema, otherwise the wildcard "resource".
+func singleSubjectType(state map[string]Resource, subjectTypes map[string]bool) string {
+	if len(subjectTypes) == 1 {
+		for subjectType := range subjectTypes {
+			if _, ok := state[subjectType]; ok {
+				return subjectType
+			}
+		}
+	}
+	return "resource"
+}
+
+func handleCaveat(cd *corev1.CaveatDefinition) Caveat {
+	caveat := Caveat{Name: cd.Name, Args: map[string]string{}}
+	for name, typeRef := range cd.ParameterTypes {
+		arg := CaveatArg{Name: name, Type: parseCaveatType(typeRef)}
+		caveat.Args[name] = arg.Type.String()
+		caveat.ArgsArray = append(caveat.ArgsArray, arg)
+	}
+	sort.Slice(caveat.ArgsArray, func(i, j int) bool { return caveat.ArgsArray[i].Name < caveat.ArgsArray[j].Name })
+	return caveat
+}
+
+func parseCaveatType(typeRef *corev1.CaveatTypeReference) CaveatType {
+	t := CaveatType{Name: typeRef.TypeName}
+	for _, child := range typeRef.ChildTypes {
+		t.Children = append(t.Children, parseCaveatType(child))
+	}
+	return t
+}
+
+// captures spicegen metatag info
+type metatag struct {
+	allowedSubjectTypes map[string]string
+	rename              string
+	ignore              bool
+	deprecated          string
+	doc                 string
+	aliases             []string
+}
+
+func parseMetatags(definition, relation string, metadata *corev1.Metadata) (metatag, error) {
+	m := metatag{}
+	scope := relationScope
+	if relation == "" {
+		scope = definitionScope
+	}
+	comments := namespace.GetComments(metadata)
+	directives, err := parseDirectives(definition, relation, scope, comments)
+	if err != nil {
+		return m, err
+	}
+	m.doc = commentText(comments)
+	for _, d := range directives {
+		switch d.key {
+		case "subject_type":
+			stypesplit := strings.SplitN(d.value, "#", 2)
+			if m.allowedSubjectTypes == nil {
+				m.allowedSubjectTypes = map[string]string{}
+			}
+			optionalSubjectRef := "..."
+			if len(stypesplit) == 2 {
+				optionalSubjectRef = stypesplit[1]
+			}
+			m.allowedSubjectTypes[stypesplit[0]] = optionalSubjectRef
+		case "rename":
+			m.rename = d.value
+		case "ignore":
+			m.ignore = true
+		case "deprecated":
+			m.deprecated = d.value
+			if m.deprecated == "" {
+				m.deprecated = "do not use."
+			}
+		case "doc":
+			m.doc = d.value
+		case "alias":
+			m.aliases = append(m.aliases, d.value)
+		}
+	}
+	return m, nil
+}
+
+func parseKind(comments []*anypb.Any) string {
+	for _, c := range comments {
+		if strings.Contains(c.String(), "kind:PERMISSION") {
+			return "permission"
+		}
+		if strings.Contains(c.String(), "kind:RELATION") {
+			return "relation"
+		}
+	}
+	return "unknown"
+}
+
+func handleRelation(resourceType string, rel *corev1.Relation) (Relation, error) {
+	relation := Relation{Name: rel.Name}
+	relation.Kind = parseKind(rel.Metadata.MetadataMessage)
+	metatag, err := parseMetatags(resourceType, rel.Name, rel.Metadata)
+	if err != nil {
+		return relation, err
+	}
+	if metatag.rename != "" {
+		relation.OutputName = metatag.rename
+	} else {
+		relation.OutputName = relation.Name
+	}
+	relation.Ignored = metatag.ignore
+	relation.Deprecated = metatag.deprecated
+	relation.Doc = metatag.doc
+	relation.Aliases = metatag.aliases
+	if rewrite := rel.GetUsersetRewrite(); rewrite != nil {
+		relation.Rewrite = buildExpr(rewrite)
+	}
+	if relation.Rewrite != nil {
+		relation.Expression = relation.Rewrite.String()
+	}
+	if metatag.allowedSubjectTypes != nil {
+		relation.OverrideAllowedSubjectTypes = metatag.allowedSubjectTypes
+	}
+	// Resolve the relation refs. For example, given a relation like: owner: user | group, we want to resolve the user and group refs
+	// to get a concrete type so we can generate a client that is typesafe. Go doesn't have union types, so if there is more than one
+	// assignable concrete type (i.e. a ObjectDefinition, referred to as Resources in this code) we generate a sealed interface that
+	// only those resources implement, and enforce the exact relation at runtime.
+	// The refs are kept even when the subject types are overridden, so that arrows through this relation can still be followed.
+	refs := make([]RelationRef, 0)
+	if relation.Rewrite != nil {
+		for _, leaf := range relation.Rewrite.Leaves() {
+			refs = append(refs, RelationRef{ResourceType: resourceType, Relation: leaf.Relation, Tupleset: leaf.Tupleset})
+		}
+	}
+	if rel.GetTypeInformation() != nil {
+		for _, m := range rel.TypeInformation.AllowedDirectRelations {
+			r := RelationRef{
+				ResourceType: m.Namespace,
+				Relation:     m.GetRelation(),
+			}
+			if m.GetPublicWildcard() != nil {
+				r.Relation = "..."
+				r.Wildcard = true
+			}
+			if m.RequiredCaveat != nil {
+				r.Caveat = m.RequiredCaveat.CaveatName
+			}
+			refs = append(refs, r)
+		}
+	}
+	relation.RelationRefs = refs
+	return relation, nil
+}
@@ -0,0 +1,515 @@
+package gen
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildSchema(t *testing.T) {
+	tests := []struct {
+		name      string
+		schematxt string
+		err       error
+		validate  func(schema Schema) error
+	}{
+		{
+			name: "simple schema",
+			schematxt: `definition user {}
+                        definition document {
+                            relation reader: user
+                            permission view = reader
+                        }`,
+			validate: func(schema Schema) error {
+				// assert basic structure
+				if len(schema.Resources) != 2 {
+					return assert.AnError
+				}
+				if len(schema.Resources["user"].Permissions) != 0 {
+					return assert.AnError
+				}
+				if len(schema.Resources["user"].Relations) != 0 {
+					return assert.AnError
+				}
+				if len(schema.Resources["document"].Permissions) != 1 {
+					return assert.AnError
+				}
+				if len(schema.Resources["document"].Relations) != 1 {
+					return assert.AnError
+				}
+				readerRel := schema.Resources["document"].Relations["reader"]
+				if readerRel.Name != "reader" ||
+					readerRel.OutputName != "reader" ||
+					readerRel.Kind != "relation" ||
+					len(readerRel.OverrideAllowedSubjectTypes) != 0 ||
+					len(readerRel.RelationRefs) != 1 ||
+					readerRel.RelationRefs[0].ResourceType != "user" ||
+					readerRel.RelationRefs[0].Relation != "..." ||
+					readerRel.RelationRefs[0].Caveat != "" {
+					return fmt.Errorf("unexpected reader relation: %+v", readerRel)
+				}
+				viewPerm := schema.Resources["document"].Permissions["view"]
+				if viewPerm.Name != "view" ||
+					viewPerm.OutputName != "view" ||
+					viewPerm.Kind != "permission" ||
+					len(viewPerm.OverrideAllowedSubjectTypes) != 0 ||
+					len(viewPerm.RelationRefs) != 1 ||
+					viewPerm.RelationRefs[0].ResourceType != "document" ||
+					viewPerm.RelationRefs[0].Relation != "reader" ||
+					viewPerm.RelationRefs[0].Caveat != "" {
+					return fmt.Errorf("unexpected view permission: %+v", viewPerm)
+				}
+				return nil
+			},
+		},
+		{
+			name: "simple schema with union",
+			schematxt: `definition user {}
+                        definition document {
+                            relation reader: user | document
+                            permission view = reader
+                        }`,
+			validate: func(schema Schema) error {
+				readerRel := schema.Resources["document"].Relations["reader"]
+				if readerRel.Name != "reader" ||
+					readerRel.OutputName != "reader" ||
+					readerRel.Kind != "relation" ||
+					len(readerRel.OverrideAllowedSubjectTypes) != 0 ||
+					len(readerRel.RelationRefs) != 2 ||
+					readerRel.RelationRefs[0].ResourceType != "user" ||
+					readerRel.RelationRefs[0].Relation != "..." ||
+					readerRel.RelationRefs[0].Caveat != "" ||
+					readerRel.RelationRefs[1].ResourceType != "document" ||
+					readerRel.RelationRefs[1].Relation != "..." ||
+					readerRel.RelationRefs[1].Caveat != "" {
+					return fmt.Errorf("unexpected reader relation: %+v", readerRel)
+				}
+				viewPerm := schema.Resources["document"].Permissions["view"]
+				if viewPerm.Name != "view" ||
+					viewPerm.OutputName != "view" ||
+					viewPerm.Kind != "permission" ||
+					len(viewPerm.OverrideAllowedSubjectTypes) != 0 ||
+					len(viewPerm.RelationRefs) != 1 ||
+					viewPerm.RelationRefs[0].ResourceType != "document" ||
+					viewPerm.RelationRefs[0].Relation != "reader" ||
+					viewPerm.RelationRefs[0].Caveat != "" {
+					return fmt.Errorf("unexpected view permission: %+v", viewPerm)
+				}
+				return nil
+			},
+		},
+		{
+			name: "simple schema with optional subject relation",
+			schematxt: `definition user {}
+                        definition team {
+                            relation member: user
+                        }
+                        definition document {
+                            relation reader: team#member
+                            permission view = reader
+                        }`,
+			validate: func(schema Schema) error {
+				readerRel := schema.Resources["document"].Relations["reader"]
+				if readerRel.Name != "reader" ||
+					readerRel.OutputName != "reader" ||
+					readerRel.Kind != "relation" ||
+					len(readerRel.RelationRefs) != 1 ||
+					readerRel.RelationRefs[0].ResourceType != "team" ||
+					readerRel.RelationRefs[0].Relation != "member" ||
+					readerRel.RelationRefs[0].Caveat != "" {
+					return fmt.Errorf("unexpected reader relation: %+v", readerRel)
+				}
+				viewPerm := schema.Resources["document"].Permissions["view"]
+				if viewPerm.Name != "view" ||
+					viewPerm.OutputName != "view" ||
+					viewPerm.Kind != "permission" ||
+					len(viewPerm.OverrideAllowedSubjectTypes) != 0 ||
+					len(viewPerm.RelationRefs) != 1 ||
+					viewPerm.RelationRefs[0].ResourceType != "document" ||
+					viewPerm.RelationRefs[0].Relation != "reader" ||
+					viewPerm.RelationRefs[0].Caveat != "" {
+					return fmt.Errorf("unexpected view permission: %+v", viewPerm)
+				}
+				return nil
+			},
+		},
+		{
+			name: "simple schema with userset rewrite",
+			schematxt: `definition user {}
+                        definition team {
+                            relation member: user
+                        }
+                        definition document {
+                            permission view = team->member
+                        }`,
+			validate: func(schema Schema) error {
+				viewPerm := schema.Resources["document"].Permissions["view"]
+				if viewPerm.Name != "view" ||
+					viewPerm.OutputName != "view" ||
+					viewPerm.Kind != "permission" ||
+					len(viewPerm.OverrideAllowedSubjectTypes) != 0 ||
+					len(viewPerm.RelationRefs) != 1 ||
+					viewPerm.RelationRefs[0].ResourceType != "document" ||
+					viewPerm.RelationRefs[0].Tupleset != "team" ||
+					viewPerm.RelationRefs[0].Relation != "member" ||
+					viewPerm.RelationRefs[0].Caveat != "" {
+					return fmt.Errorf("unexpected view permission: %+v", viewPerm)
+				}
+				return nil
+			},
+		},
+		{
+			name: "subject types resolved through arrows and subject relations",
+			schematxt: `definition user {}
+                        definition team {
+                            relation member: user | team#member
+                        }
+                        definition organization {
+                            relation administrator: user | team#member
+                            permission admin = administrator
+                        }
+                        definition document {
+                            relation docorg: organization
+                            relation reader: user
+                            permission view = reader + docorg->admin
+                        }`,
+			validate: func(schema Schema) error {
+				teamMember := schema.Resources["team"].Relations["member"]
+				if len(teamMember.AllowedSubjectTypes) != 2 ||
+					teamMember.AllowedSubjectTypes["user"] != "..." ||
+					teamMember.AllowedSubjectTypes["team"] != "member" {
+					return fmt.Errorf("unexpected team member relation: %+v", teamMember)
+				}
+				viewPerm := schema.Resources["document"].Permissions["view"]
+				if len(viewPerm.AllowedSubjectTypes) != 1 || viewPerm.AllowedSubjectTypes["user"] != "..." {
+					return fmt.Errorf("unexpected view permission: %+v", viewPerm)
+				}
+				document := schema.Resources["document"]
+				if document.PermissionSubjectType != "user" || document.RelationSubjectType != "resource" {
+					return fmt.Errorf("unexpected document subject types: %s, %s", document.PermissionSubjectType, document.RelationSubjectType)
+				}
+				if schema.Resources["team"].RelationSubjectType != "resource" {
+					return fmt.Errorf("unexpected team subject type: %s", schema.Resources["team"].RelationSubjectType)
+				}
+				if len(teamMember.SubjectUnion) != 2 || teamMember.SubjectUnion[0] != "team" || teamMember.SubjectUnion[1] != "user" {
+					return fmt.Errorf("unexpected team member subject union: %v", teamMember.SubjectUnion)
+				}
+				if len(document.RelationSubjectUnion) != 2 || document.RelationSubjectUnion[0] != "organization" || document.RelationSubjectUnion[1] != "user" {
+					return fmt.Errorf("unexpected document subject union: %v", document.RelationSubjectUnion)
+				}
+				if document.Relations["reader"].SubjectUnion != nil {
+					return fmt.Errorf("unexpected reader subject union: %v", document.Relations["reader"].SubjectUnion)
+				}
+				return nil
+			},
+		},
+		{
+			name: "subject types use the metatag override",
+			schematxt: `definition user {}
+                        definition team {
+                            relation member: user
+                        }
+                        definition organization {
+                            relation administrator: user | team
+                            /** //spicegen:subject_type=user */
+                            permission admin = administrator
+                        }
+                        definition document {
+                            relation docorg: organization
+                            permission view = docorg->admin
+                        }`,
+			validate: func(schema Schema) error {
+				organization := schema.Resources["organization"]
+				if organization.PermissionSubjectType != "user" || organization.RelationSubjectType != "resource" {
+					return fmt.Errorf("unexpected organization subject types: %s, %s", organization.PermissionSubjectType, organization.RelationSubjectType)
+				}
+				document := schema.Resources["document"]
+				if document.PermissionSubjectType != "user" || document.RelationSubjectType != "organization" {
+					return fmt.Errorf("unexpected document subject types: %s, %s", document.PermissionSubjectType, document.RelationSubjectType)
+				}
+				return nil
+			},
+		},
+		{
+			name: "simple schema with caveat",
+			schematxt: `definition user {}
+                        caveat ip_allowlist(user_ip ipaddress, allowed_ranges list<string>) {
+                            allowed_ranges.exists(r, user_ip.in_cidr(r))
+                        }
+                        definition document {
+                            relation reader: user with ip_allowlist
+                        }`,
+			validate: func(schema Schema) error {
+				caveat := schema.Caveats["ip_allowlist"]
+				if caveat.Name != "ip_allowlist" ||
+					len(caveat.Args) != 2 ||
+					caveat.Args["user_ip"] != "ipaddress" ||
+					caveat.Args["allowed_ranges"] != "list<string>" ||
+					len(caveat.ArgsArray) != 2 ||
+					caveat.ArgsArray[0].Name != "allowed_ranges" ||
+					caveat.ArgsArray[1].Name != "user_ip" {
+					return fmt.Errorf("unexpected caveat: %+v", caveat)
+				}
+				readerRel := schema.Resources["document"].Relations["reader"]
+				if len(readerRel.RelationRefs) != 1 ||
+					readerRel.RelationRefs[0].ResourceType != "user" ||
+					readerRel.RelationRefs[0].Caveat != "ip_allowlist" {
+					return fmt.Errorf("unexpected reader relation: %+v", readerRel)
+				}
+				return nil
+			},
+		},
+		{
+			name: "simple schema with wildcard",
+			schematxt: `definition user {}
+                        definition document {
+                            relation viewer: user | user:*
+                            permission view = viewer
+                        }`,
+			validate: func(schema Schema) error {
+				viewerRel := schema.Resources["document"].Relations["viewer"]
+				if len(viewerRel.RelationRefs) != 2 ||
+					viewerRel.RelationRefs[0].Wildcard ||
+					!viewerRel.RelationRefs[1].Wildcard ||
+					viewerRel.RelationRefs[1].ResourceType != "user" ||
+					viewerRel.RelationRefs[1].Relation != "..." {
+					return fmt.Errorf("unexpected viewer relation: %+v", viewerRel)
+				}
+				if schema.Resources["document"].PermissionSubjectType != "user" {
+					return fmt.Errorf("unexpected document subject type: %s", schema.Resources["document"].PermissionSubjectType)
+				}
+				return nil
+			},
+		},
+		{
+			name: "unknown metatag",
+			schematxt: `definition user {}
+                        definition document {
+                            /** reader can read //spicegen:subject_type=user //spicegen:renamed=viewer */
+                            relation reader: user
+                        }`,
+			err: fmt.Errorf(`4:29: invalid metatag "//spicegen:renamed=viewer" on document#reader: unknown metatag`),
+		},
+		{
+			name: "ignore, deprecated, doc and alias metatags",
+			schematxt: `definition user {}
+                        /** //spicegen:ignore */
+                        definition legacy {
+                            relation owner: user
+                        }
+                        /** //spicegen:deprecated //spicegen:doc="a document" */
+                        definition document {
+                            /** //spicegen:ignore */
+                            relation internal: user
+                            /** //spicegen:deprecated="use viewer" //spicegen:alias=reader //spicegen:alias=read */
+                            relation viewer: user
+                            permission view = viewer + internal
+                        }`,
+			validate: func(schema Schema) error {
+				if _, ok := schema.Resources["legacy"]; ok {
+					return fmt.Errorf("expected legacy to be ignored")
+				}
+				document := schema.Resources["document"]
+				if document.Deprecated != "do not use." || document.Doc != "a document" {
+					return fmt.Errorf("unexpected document: %+v", document)
+				}
+				if _, ok := document.Relations["internal"]; ok {
+					return fmt.Errorf("expected internal to be ignored")
+				}
+				viewer := document.Relations["viewer"]
+				if viewer.Deprecated != "use viewer" || len(viewer.Aliases) != 2 || viewer.Aliases[0] != "reader" || viewer.Aliases[1] != "read" {
+					return fmt.Errorf("unexpected viewer relation: %+v", viewer)
+				}
+				if document.PermissionSubjectType != "user" {
+					return fmt.Errorf("unexpected document subject type: %s", document.PermissionSubjectType)
+				}
+				return nil
+			},
+		},
+		{
+			name: "doc comments and rewrite expressions",
+			schematxt: `definition user {}
+                        /** organization represents an organization */
+                        definition organization {
+                            relation administrator: user
+                            permission view_all_documents = administrator
+                        }
+                        /** document represents a document */
+                        definition document {
+                            relation docorg: organization
+                            /** reader indicates that the user is a reader */
+                            relation reader: user
+                            relation writer: user
+                            relation banned: user
+                            /**
+                             * view indicates whether the user can view the document
+                             * //spicegen:subject_type=user
+                             */
+                            permission view = (reader + writer + docorg->view_all_documents) - banned
+                            permission edit = writer & docorg->view_all_documents
+                        }`,
+			validate: func(schema Schema) error {
+				document := schema.Resources["document"]
+				if document.Doc != "document represents a document" {
+					return fmt.Errorf("unexpected document doc: %q", document.Doc)
+				}
+				if document.Relations["reader"].Doc != "reader indicates that the user is a reader" || document.Relations["writer"].Doc != "" {
+					return fmt.Errorf("unexpected relation docs: %+v", document.Relations)
+				}
+				view := document.Permissions["view"]
+				if view.Doc != "view indicates whether the user can view the document" ||
+					view.Expression != "(reader + writer + docorg->view_all_documents) - banned" {
+					return fmt.Errorf("unexpected view permission: %+v", view)
+				}
+				if edit := document.Permissions["edit"]; edit.Expression != "writer & docorg->view_all_documents" {
+					return fmt.Errorf("unexpected edit permission: %+v", edit)
+				}
+				return nil
+			},
+		},
+		{
+			name: "permission expression trees",
+			schematxt: `definition user {}
+                        definition team {
+                            relation member: user
+                        }
+                        definition document {
+                            relation reader: user
+                            relation writer: user | team
+                            relation banned: team
+                            permission view = reader - banned
+                            permission edit = reader & writer
+                            permission admin = (reader + writer) - (banned + nil)
+                        }`,
+			validate: func(schema Schema) error {
+				document := schema.Resources["document"]
+				view := document.Permissions["view"]
+				if view.Rewrite == nil || view.Rewrite.Kind != ExclusionExpr || len(view.Rewrite.Children) != 2 ||
+					view.Rewrite.Children[0].Kind != ComputedUsersetExpr || view.Rewrite.Children[1].Relation != "banned" {
+					return fmt.Errorf("unexpected view rewrite: %+v", view.Rewrite)
+				}
+				if leaves := view.Rewrite.GrantingLeaves(); len(leaves) != 1 || leaves[0].Relation != "reader" {
+					return fmt.Errorf("unexpected view granting leaves: %+v", leaves)
+				}
+				// banned can only hold teams, so it must not add to the subject types of view
+				if len(view.AllowedSubjectTypes) != 1 || view.AllowedSubjectTypes["user"] != "..." {
+					return fmt.Errorf("unexpected view subject types: %+v", view.AllowedSubjectTypes)
+				}
+				edit := document.Permissions["edit"]
+				if edit.Rewrite.Kind != IntersectionExpr || len(edit.AllowedSubjectTypes) != 1 || edit.AllowedSubjectTypes["user"] != "..." {
+					return fmt.Errorf("unexpected edit permission: %+v", edit)
+				}
+				admin := document.Permissions["admin"]
+				if admin.Expression != "(reader + writer) - (banned + nil)" || admin.Rewrite.Children[1].Children[1].Kind != NilExpr {
+					return fmt.Errorf("unexpected admin permission: %+v", admin)
+				}
+				if leaves := admin.Rewrite.Leaves(); len(leaves) != 3 {
+					return fmt.Errorf("unexpected admin leaves: %+v", leaves)
+				}
+				if document.Relations["reader"].Rewrite != nil {
+					return fmt.Errorf("unexpected reader rewrite: %+v", document.Relations["reader"].Rewrite)
+				}
+				return nil
+			},
+		},
+		{
+			name: "prefixed definitions",
+			schematxt: `definition acme/user {}
+                        definition billing/invoice {
+                            relation owner: acme/user
+                            permission view = owner
+                        }
+                        definition crm/invoice {
+                            relation owner: acme/user
+                        }
+                        definition doc_type {}`,
+			validate: func(schema Schema) error {
+				for name, expected := range map[string][3]string{
+					"billing/invoice": {"BillingInvoice", "invoice", "billing_invoice"},
+					"crm/invoice":     {"CrmInvoice", "invoice", "crm_invoice"},
+					"doc_type":        {"DocType", "doc_type", "doc_type"},
+				} {
+					rsc := schema.Resources[name]
+					if actual := [3]string{rsc.GoName, rsc.PackageName, rsc.PackageAlias}; actual != expected {
+						return fmt.Errorf("unexpected names for %s: %v", name, actual)
+					}
+				}
+				if schema.Resources["billing/invoice"].PermissionSubjectType != "acme/user" {
+					return fmt.Errorf("unexpected invoice subject type: %s", schema.Resources["billing/invoice"].PermissionSubjectType)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			prefix := ""
+			compiledSchema, _ := compiler.Compile(compiler.InputSchema{SchemaString: string(tc.schematxt)}, compiler.ObjectTypePrefix(prefix))
+			schema, err := BuildSchema(compiledSchema)
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+				return
+			}
+			assert.NoError(t, err)
+			assert.NoError(t, tc.validate(schema))
+		})
+	}
+}
+
+func TestStripObjectPrefix(t *testing.T) {
+	tests := []struct {
+		name      string
+		schematxt string
+		validate  func(schema Schema) error
+		err       error
+	}{
+		{
+			name: "prefixed schema",
+			schematxt: `definition tenant1/user {}
+                        caveat tenant1/on_weekday(day int) { day < 6 }
+                        definition tenant1/document {
+                            relation reader: tenant1/user with tenant1/on_weekday
+                            permission view = reader
+                        }`,
+			validate: func(schema Schema) error {
+				document, ok := schema.Resources["document"]
+				if !ok || document.GoName != "Document" || document.PermissionSubjectType != "user" {
+					return fmt.Errorf("unexpected document: %+v", document)
+				}
+				if ref := document.Relations["reader"].RelationRefs[0]; ref.ResourceType != "user" || ref.Caveat != "on_weekday" {
+					return fmt.Errorf("unexpected reader ref: %+v", ref)
+				}
+				if _, ok := schema.Caveats["on_weekday"]; !ok {
+					return fmt.Errorf("unexpected caveats: %+v", schema.Caveats)
+				}
+				return nil
+			},
+		},
+		{
+			name: "definition without the prefix",
+			schematxt: `definition tenant1/user {}
+                        definition tenant2/document {
+                            relation reader: tenant1/user
+                        }`,
+			err: errors.New("definition tenant2/document does not have the object prefix tenant1"),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			compiledSchema, err := compiler.Compile(compiler.InputSchema{SchemaString: tc.schematxt}, compiler.ObjectTypePrefix(""))
+			assert.NoError(t, err)
+			err = StripObjectPrefix(compiledSchema, "tenant1")
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+				return
+			}
+			assert.NoError(t, err)
+			schema, err := BuildSchema(compiledSchema)
+			assert.NoError(t, err)
+			assert.NoError(t, tc.validate(schema))
+		})
+	}
+}
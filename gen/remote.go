@@ -0,0 +1,81 @@
+package gen
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// RemoteSchemaOptions configures the connection to a running spicedb
+type RemoteSchemaOptions struct {
+	Token    string // preshared key or bearer token, sent with every request
+	Insecure bool   // connect without TLS, i.e. to a local spicedb
+	CACert   string // path to a PEM encoded CA certificate, the system certificates are used if empty
+	// Additional dial options, i.e. a custom dialer
+	DialOptions []grpc.DialOption
+}
+
+// Sends the token as a bearer token with every request
+type bearerToken struct {
+	token    string
+	insecure bool
+}
+
+func (t bearerToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + t.token}, nil
+}
+
+func (t bearerToken) RequireTransportSecurity() bool {
+	return !t.insecure
+}
+
+// Dials the schema service of the spicedb at endpoint. The returned connection must be closed by the caller.
+func DialSchemaService(endpoint string, opts RemoteSchemaOptions) (pb.SchemaServiceClient, *grpc.ClientConn, error) {
+	dialOpts := append([]grpc.DialOption{}, opts.DialOptions...)
+	if opts.Insecure {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	} else {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if opts.CACert != "" {
+			pem, err := os.ReadFile(opts.CACert)
+			if err != nil {
+				return nil, nil, fmt.Errorf("error reading CA certificate: %w", err)
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, nil, fmt.Errorf("no certificates found in CA certificate %s", opts.CACert)
+			}
+		}
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	}
+	if opts.Token != "" {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(bearerToken{token: opts.Token, insecure: opts.Insecure}))
+	}
+	conn, err := grpc.Dial(endpoint, dialOpts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error connecting to %s: %w", endpoint, err)
+	}
+	return pb.NewSchemaServiceClient(conn), conn, nil
+}
+
+// Reads the schema deployed to spicedb and compiles it. Compile errors point at the endpoint as the schema source.
+func CompileRemoteSchema(ctx context.Context, endpoint string, client pb.SchemaServiceClient) (*compiler.CompiledSchema, error) {
+	resp, err := client.ReadSchema(ctx, &pb.ReadSchemaRequest{})
+	if status.Code(err) == codes.NotFound {
+		return nil, fmt.Errorf("no schema has been written to %s", endpoint)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema from %s: %w", endpoint, err)
+	}
+	return compileSchema(endpoint, resp.SchemaText)
+}
@@ -0,0 +1,174 @@
+package gen
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/spicedb/pkg/cmd/datastore"
+	"github.com/authzed/spicedb/pkg/cmd/server"
+	"github.com/authzed/spicedb/pkg/cmd/util"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// An in-process schema service serving a fixed schema to clients with the right token, failing like spicedb does
+type schemaServer struct {
+	pb.UnimplementedSchemaServiceServer
+	schema string
+	token  string
+}
+
+func (s *schemaServer) ReadSchema(ctx context.Context, req *pb.ReadSchemaRequest) (*pb.ReadSchemaResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if auth := md.Get("authorization"); len(auth) != 1 || auth[0] != "Bearer "+s.token {
+		return nil, status.Error(codes.PermissionDenied, "invalid preshared key: invalid token")
+	}
+	if s.schema == "" {
+		return nil, status.Error(codes.NotFound, "No schema has been defined; please call WriteSchema to start")
+	}
+	return &pb.ReadSchemaResponse{SchemaText: s.schema}, nil
+}
+
+// Starts the schema service on an in-memory listener, returning the endpoint and the dial options connecting to it
+func startSchemaServer(t *testing.T, token, schema string) (string, []grpc.DialOption) {
+	listener := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	pb.RegisterSchemaServiceServer(srv, &schemaServer{schema: schema, token: token})
+	go srv.Serve(listener)
+	t.Cleanup(srv.Stop)
+	return "bufnet", []grpc.DialOption{grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	})}
+}
+
+// Starts an in-process spicedb requiring the preshared key token, with schema written to it if not empty, returning
+// its address and the dial options waiting for it to serve
+func startSpiceDB(t *testing.T, token, schema string) (string, []grpc.DialOption) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	// The listener of the server isn't exposed, so reserve a free port for it
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ds, err := datastore.NewDatastore(ctx,
+		datastore.DefaultDatastoreConfig().ToOption(),
+		datastore.WithRequestHedgingEnabled(false),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := server.NewConfigWithOptionsAndDefaults(
+		server.WithGRPCServer(util.GRPCServerConfig{Network: "tcp", Address: addr, Enabled: true}),
+		server.WithPresharedSecureKey(token),
+		server.WithHTTPGateway(util.HTTPServerConfig{HTTPEnabled: false}),
+		server.WithMetricsAPI(util.HTTPServerConfig{HTTPEnabled: false}),
+		server.WithDispatchServer(util.GRPCServerConfig{Enabled: false}),
+		server.WithDispatchCacheConfig(server.CacheConfig{Enabled: false, Metrics: false}),
+		server.WithNamespaceCacheConfig(server.CacheConfig{Enabled: false, Metrics: false}),
+		server.WithClusterDispatchCacheConfig(server.CacheConfig{Enabled: false, Metrics: false}),
+		server.WithDatastore(ds),
+	).Complete(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Run(ctx)
+
+	if schema != "" {
+		conn, err := srv.GRPCDialContext(ctx, grpc.WithDefaultCallOptions(grpc.WaitForReady(true)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		_, err = pb.NewSchemaServiceClient(conn).WriteSchema(ctx, &pb.WriteSchemaRequest{Schema: schema})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return addr, []grpc.DialOption{grpc.WithDefaultCallOptions(grpc.WaitForReady(true))}
+}
+
+func TestCompileRemoteSchema(t *testing.T) {
+	servers := map[string]func(t *testing.T, token, schema string) (string, []grpc.DialOption){
+		"stub":    startSchemaServer,
+		"spicedb": startSpiceDB,
+	}
+	tests := []struct {
+		name     string
+		schema   string
+		token    string
+		stubOnly bool // spicedb rejects writing an invalid schema
+		validate func(t *testing.T, schema Schema)
+		err      string
+	}{
+		{
+			name:   "deployed schema",
+			schema: "definition user {}\ndefinition document {\n relation reader: user\n permission view = reader\n}",
+			token:  "secret",
+			validate: func(t *testing.T, schema Schema) {
+				assert.Len(t, schema.Resources, 2)
+				assert.Equal(t, "user", schema.Resources["document"].PermissionSubjectType)
+			},
+		},
+		{
+			name:   "invalid token",
+			schema: "definition user {}",
+			token:  "wrong",
+			err:    "error reading schema from %s: rpc error: code = PermissionDenied desc = invalid preshared key: invalid token",
+		},
+		{
+			name:  "no schema",
+			token: "secret",
+			err:   "no schema has been written to %s",
+		},
+		{
+			name:     "compile errors point at the endpoint",
+			schema:   "definition user {\n relation x: user |\n}",
+			token:    "secret",
+			stubOnly: true,
+			err:      "%s:3:1: Expected identifier, found token TokenTypeRightBrace",
+		},
+	}
+	for server, start := range servers {
+		for _, tc := range tests {
+			t.Run(server+"/"+tc.name, func(t *testing.T) {
+				if server == "spicedb" && (tc.stubOnly || testing.Short()) {
+					t.Skip()
+				}
+				endpoint, dialOpts := start(t, "secret", tc.schema)
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+
+				client, conn, err := DialSchemaService(endpoint, RemoteSchemaOptions{
+					Token:       tc.token,
+					Insecure:    true,
+					DialOptions: dialOpts,
+				})
+				assert.NoError(t, err)
+				defer conn.Close()
+				compiled, err := CompileRemoteSchema(ctx, endpoint, client)
+				if tc.err != "" {
+					assert.EqualError(t, err, fmt.Sprintf(tc.err, endpoint))
+					return
+				}
+				assert.NoError(t, err)
+				schema, err := BuildSchema(compiled)
+				assert.NoError(t, err)
+				tc.validate(t, schema)
+			})
+		}
+	}
+}
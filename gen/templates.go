@@ -0,0 +1,119 @@
+package gen
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/iancoleman/strcase"
+)
+
+// The templates used for a generation run, the embedded templates unless overridden by the template directory
+type templates struct {
+	Types    string
+	Client   string
+	Resource string
+	Extra    []extraTemplate // sorted by name
+}
+
+// A user-supplied template rendered once with the whole schema, i.e. wrappers.text generates wrappers.go
+type extraTemplate struct {
+	Name string
+	Path string // the generated file, relative to the output directory
+	Text string
+}
+
+// Reads the templates in dir. types.text, client.text and resource.text replace the embedded templates, any other
+// .text file is an extra template generating a file of the same name in the output directory.
+func loadTemplates(dir string) (templates, error) {
+	tmpls := templates{Types: typestmptext, Client: clienttmptext, Resource: resourcetmptext, Extra: make([]extraTemplate, 0)}
+	if dir == "" {
+		return tmpls, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return tmpls, fmt.Errorf("error reading template directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".text" {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return tmpls, fmt.Errorf("error reading template: %w", err)
+		}
+		switch name := strings.TrimSuffix(entry.Name(), ".text"); name {
+		case "types":
+			tmpls.Types = string(content)
+		case "client":
+			tmpls.Client = string(content)
+		case "resource":
+			tmpls.Resource = string(content)
+		default:
+			tmpls.Extra = append(tmpls.Extra, extraTemplate{Name: entry.Name(), Path: name + ".go", Text: string(content)})
+		}
+	}
+	sort.Slice(tmpls.Extra, func(i, j int) bool { return tmpls.Extra[i].Name < tmpls.Extra[j].Name })
+	return tmpls, nil
+}
+
+// Returns the plural of an English noun, i.e. policy -> policies, for naming generated collections
+func plural(word string) string {
+	lower := strings.ToLower(word)
+	switch {
+	case word == "":
+		return word
+	case strings.HasSuffix(lower, "s"), strings.HasSuffix(lower, "x"), strings.HasSuffix(lower, "z"),
+		strings.HasSuffix(lower, "ch"), strings.HasSuffix(lower, "sh"):
+		return word + "es"
+	case len(word) > 1 && strings.HasSuffix(lower, "y") && !strings.ContainsRune("aeiou", rune(lower[len(lower)-2])):
+		if unicode.IsUpper(rune(word[len(word)-1])) {
+			return word[:len(word)-1] + "IES"
+		}
+		return word[:len(word)-1] + "ies"
+	case unicode.IsUpper(rune(word[len(word)-1])):
+		return word + "S"
+	}
+	return word + "s"
+}
+
+// Formats the doc text and deprecation notice as a single line for a markdown table cell
+func markdownCell(doc, deprecated string) string {
+	cell := strings.Join(strings.Fields(doc), " ")
+	if deprecated != "" {
+		cell = strings.TrimSpace(cell + " **Deprecated:** " + deprecated)
+	}
+	return strings.ReplaceAll(cell, "|", "\\|")
+}
+
+// The helper funcs available to templates
+func templateFuncs() map[string]any {
+	return map[string]any{
+		"ToUpper":             strings.ToUpper,
+		"ToLower":             strings.ToLower,
+		"ToCamel":             strcase.ToCamel,
+		"ToLowerCamel":        strcase.ToLowerCamel,
+		"ToSnake":             strcase.ToSnake,
+		"Plural":              plural,
+		"GoName":              goName,
+		"SubjectType":         subjectType,
+		"RelationSubjectType": relationSubjectType,
+		"AllowedSubjects":     allowedSubjects,
+		"CaveatWriters":       caveatWriters,
+		"RelationWriters":     relationWriters,
+		"WildcardTypes":       wildcardTypes,
+		"DocComment":          docComment,
+		"ResourceDoc":         resourceDoc,
+		"RelationDoc":         relationDoc,
+		"AllowsWildcard":      func(rsc Resource) bool { return len(wildcardTypes([]Resource{rsc})) > 0 },
+		"CaveatGoType":        caveatGoType,
+		"CaveatValue":         caveatValue,
+		"CaveatNillable":      caveatNillable,
+		"CaveatFieldType":     caveatFieldType,
+		"CaveatFieldValue":    caveatFieldValue,
+		"MarkdownCell":        markdownCell,
+	}
+}
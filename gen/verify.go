@@ -0,0 +1,209 @@
+package gen
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The most type errors reported, like the go compiler
+const maxVerifyErrors = 10
+
+// Where a generated Go file came from, to map type errors back to the template and schema
+type goSource struct {
+	template string            // i.e. client.text
+	element  string            // the schema element the whole file is generated for, if any, i.e. definition document
+	owners   map[string]string // the Go identifiers of the package to the schema element declaring them
+}
+
+// Returns the schema element generating the innermost declaration at pos, i.e. the definition of a client method
+func (s goSource) elementAt(file *ast.File, pos token.Pos) string {
+	element := s.element
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n == nil || pos < n.Pos() || pos >= n.End() {
+			return false
+		}
+		var names []*ast.Ident
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			names = []*ast.Ident{n.Name}
+		case *ast.TypeSpec:
+			names = []*ast.Ident{n.Name}
+		case *ast.ValueSpec:
+			names = n.Names
+		case *ast.Field:
+			// i.e. the methods of the client interface
+			names = n.Names
+		}
+		for _, name := range names {
+			if owner := s.owners[name.Name]; owner != "" {
+				element = owner
+			}
+		}
+		return true
+	})
+	return element
+}
+
+// Type checks the generated Go packages together with go/types, so template bugs are reported before anything is
+// written. The packages imported from outside the generated code are loaded from their export data, built by go list
+// in cfg.VerifyDir.
+func verifyGo(ctx context.Context, files []GeneratedFile, sources map[string]goSource, cfg Config) error {
+	v := &verifier{
+		fset:    token.NewFileSet(),
+		sources: sources,
+		pkgs:    map[string][]*ast.File{},
+		checked: map[string]*types.Package{},
+	}
+	paths := make([]string, 0)
+	for _, file := range files {
+		if path.Ext(file.Path) != ".go" {
+			continue
+		}
+		// the files were formatted already, so they parse
+		f, err := parser.ParseFile(v.fset, file.Path, file.Content, 0)
+		if err != nil {
+			return fmt.Errorf("error parsing %s: %w", file.Path, err)
+		}
+		importPath := path.Join(cfg.ImportPath, path.Dir(file.Path))
+		if _, ok := v.pkgs[importPath]; !ok {
+			paths = append(paths, importPath)
+		}
+		v.pkgs[importPath] = append(v.pkgs[importPath], f)
+	}
+	imports := map[string]bool{}
+	for _, pkg := range v.pkgs {
+		for _, f := range pkg {
+			for _, spec := range f.Imports {
+				if imported, err := strconv.Unquote(spec.Path.Value); err == nil && v.pkgs[imported] == nil {
+					imports[imported] = true
+				}
+			}
+		}
+	}
+	exports, err := exportData(ctx, cfg.VerifyDir, imports)
+	if err != nil {
+		return err
+	}
+	v.gc = importer.ForCompiler(v.fset, "gc", func(path string) (io.ReadCloser, error) {
+		if exports[path] == "" {
+			return nil, fmt.Errorf("no export data for %s", path)
+		}
+		return os.Open(exports[path])
+	})
+	sort.Strings(paths)
+	for _, importPath := range paths {
+		if _, err := v.Import(importPath); err != nil {
+			return err
+		}
+	}
+	if len(v.errs) == 0 {
+		return nil
+	}
+	if len(v.errs) > maxVerifyErrors {
+		v.errs = append(v.errs[:maxVerifyErrors], "too many errors")
+	}
+	return fmt.Errorf("the generated code does not compile:\n\t%s", strings.Join(v.errs, "\n\t"))
+}
+
+// Imports the generated packages from the parsed files and any other package from its export data
+type verifier struct {
+	fset    *token.FileSet
+	sources map[string]goSource
+	pkgs    map[string][]*ast.File // by import path
+	checked map[string]*types.Package
+	gc      types.Importer
+	errs    []string
+}
+
+func (v *verifier) Import(importPath string) (*types.Package, error) {
+	files, ok := v.pkgs[importPath]
+	if !ok {
+		return v.gc.Import(importPath)
+	}
+	if pkg, ok := v.checked[importPath]; ok {
+		return pkg, nil
+	}
+	conf := types.Config{
+		Importer: v,
+		Error: func(err error) {
+			var typeErr types.Error
+			if !errors.As(err, &typeErr) {
+				v.errs = append(v.errs, err.Error())
+				return
+			}
+			position := v.fset.Position(typeErr.Pos)
+			for _, f := range files {
+				if v.fset.File(f.Pos()).Name() == position.Filename {
+					v.report(position, typeErr.Pos, f, typeErr.Msg)
+				}
+			}
+		},
+	}
+	pkg, _ := conf.Check(importPath, v.fset, files, nil)
+	v.checked[importPath] = pkg
+	return pkg, nil
+}
+
+// Records an error in a generated file, i.e. types.go:12:3: undefined: flag (types.text, definition flag)
+func (v *verifier) report(position token.Position, pos token.Pos, file *ast.File, msg string) {
+	source := v.sources[position.Filename]
+	from := source.template
+	if element := source.elementAt(file, pos); element != "" {
+		from += ", " + element
+	}
+	v.errs = append(v.errs, fmt.Sprintf("%s: %s (%s)", position, msg, from))
+}
+
+// Returns the export data files of the packages and their dependencies, built by go list in the directory, or its
+// closest existing parent
+func exportData(ctx context.Context, dir string, imports map[string]bool) (map[string]string, error) {
+	exports := map[string]string{}
+	if len(imports) == 0 {
+		return exports, nil
+	}
+	args := []string{"list", "-export", "-deps", "-f", "{{.ImportPath}}={{.Export}}"}
+	for importPath := range imports {
+		args = append(args, importPath)
+	}
+	sort.Strings(args[5:])
+	for dir != "" {
+		if _, err := os.Stat(dir); err == nil || !errors.Is(err, fs.ErrNotExist) || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	stderr := &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("error loading the imports of the generated code: %w", ctx.Err())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading the imports of the generated code: %w\n%s", err, strings.TrimSpace(stderr.String()))
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		importPath, export, _ := strings.Cut(scanner.Text(), "=")
+		exports[importPath] = export
+	}
+	return exports, nil
+}
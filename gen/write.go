@@ -0,0 +1,215 @@
+package gen
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ManifestFileName lists the files spicegen generated in the output directory, so they can be removed once they are
+// not generated anymore
+const ManifestFileName = ".spicegen-manifest"
+
+// WriteFiles writes the generated files to the output directory. The files are written to a temporary directory
+// first and only moved into place once all of them were written, so a failed run leaves the output as it was. Files
+// spicegen generated earlier but does not generate anymore are removed (see StaleFiles), and the manifest is updated
+// with the generated files. Unchanged files are left alone.
+func WriteFiles(outputDir string, files []GeneratedFile) error {
+	stale, err := StaleFiles(outputDir, files)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("error creating directory: %w", err)
+	}
+	// within the output directory so the files can be renamed into place, the leading dot hides it from go ./...
+	tmpDir, err := os.MkdirTemp(outputDir, ".spicegen-")
+	if err != nil {
+		return fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	changed := make([]string, 0)
+	for _, file := range files {
+		current, err := os.ReadFile(filepath.Join(outputDir, file.Path))
+		if err == nil && bytes.Equal(current, file.Content) {
+			continue
+		}
+		if err := writeFile(filepath.Join(tmpDir, file.Path), file.Content); err != nil {
+			return err
+		}
+		changed = append(changed, file.Path)
+	}
+	manifest := &strings.Builder{}
+	manifest.WriteString("# " + generatedMarker + ". DO NOT EDIT\n")
+	manifest.WriteString("# The files spicegen generated in this directory. Listed files that are not generated anymore are removed.\n")
+	for _, path := range generatedPaths(files) {
+		manifest.WriteString(path + "\n")
+	}
+	if err := writeFile(filepath.Join(tmpDir, ManifestFileName), []byte(manifest.String())); err != nil {
+		return err
+	}
+
+	for _, path := range changed {
+		dst := filepath.Join(outputDir, path)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return fmt.Errorf("error creating directory: %w", err)
+		}
+		if err := os.Rename(filepath.Join(tmpDir, path), dst); err != nil {
+			return fmt.Errorf("error writing file: %w", err)
+		}
+	}
+	for _, path := range stale {
+		if err := removeFile(outputDir, path); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(filepath.Join(tmpDir, ManifestFileName), filepath.Join(outputDir, ManifestFileName)); err != nil {
+		return fmt.Errorf("error writing manifest: %w", err)
+	}
+	return nil
+}
+
+func writeFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating directory: %w", err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("error writing file: %w", err)
+	}
+	return nil
+}
+
+// Removes the file and any directories left empty by it, up to the output directory
+func removeFile(outputDir, path string) error {
+	if err := os.Remove(filepath.Join(outputDir, path)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error removing stale file: %w", err)
+	}
+	for dir := filepath.Dir(path); dir != "."; dir = filepath.Dir(dir) {
+		entries, err := os.ReadDir(filepath.Join(outputDir, dir))
+		if err != nil || len(entries) > 0 {
+			break
+		}
+		if err := os.Remove(filepath.Join(outputDir, dir)); err != nil {
+			return fmt.Errorf("error removing stale directory: %w", err)
+		}
+	}
+	return nil
+}
+
+// Returns the sorted, slash separated paths of the files
+func generatedPaths(files []GeneratedFile) []string {
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = filepath.ToSlash(file.Path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// StaleFiles returns the files spicegen generated earlier in the output directory but does not generate anymore, i.e.
+// for a deleted definition. These are the files listed in the manifest that still carry the spicegen marker on their
+// first line, in whatever comment syntax the file uses, so hand written files are never returned. Without a manifest,
+// i.e. for output generated before manifests were written, the output directory and the top level directories the
+// files are generated in (i.e. permissions) are searched for Go files with the header instead.
+func StaleFiles(outputDir string, files []GeneratedFile) ([]string, error) {
+	generated := map[string]bool{}
+	for _, path := range generatedPaths(files) {
+		generated[path] = true
+	}
+	candidates, err := readManifest(outputDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		candidates, err = generatedFiles(outputDir, generated)
+	}
+	if err != nil {
+		return nil, err
+	}
+	stale := make([]string, 0)
+	for _, path := range candidates {
+		if generated[path] {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(outputDir, path))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", path, err)
+		}
+		if isGenerated(content) {
+			stale = append(stale, path)
+		}
+	}
+	sort.Strings(stale)
+	return stale, nil
+}
+
+// Returns whether the first line of the file carries the spicegen marker
+func isGenerated(content []byte) bool {
+	firstLine, _, _ := bytes.Cut(content, []byte("\n"))
+	return bytes.Contains(firstLine, []byte(generatedMarker))
+}
+
+// Returns the files listed in the manifest of the output directory. Entries outside the output directory are ignored.
+func readManifest(outputDir string) ([]string, error) {
+	content, err := os.ReadFile(filepath.Join(outputDir, ManifestFileName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error reading manifest: %w", err)
+	}
+	paths := make([]string, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || !filepath.IsLocal(filepath.FromSlash(line)) {
+			continue
+		}
+		paths = append(paths, line)
+	}
+	return paths, nil
+}
+
+// Returns the .go files in the output directory and the top level directories any of the generated files are in
+func generatedFiles(outputDir string, generated map[string]bool) ([]string, error) {
+	paths := make([]string, 0)
+	entries, err := os.ReadDir(outputDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("error reading output directory: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".go" {
+			paths = append(paths, entry.Name())
+		}
+	}
+	roots := map[string]bool{}
+	for path := range generated {
+		if dir, _, nested := strings.Cut(path, "/"); nested {
+			roots[dir] = true
+		}
+	}
+	for root := range roots {
+		err := filepath.WalkDir(filepath.Join(outputDir, root), func(path string, d fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) && path == filepath.Join(outputDir, root) {
+				return filepath.SkipDir
+			}
+			if err != nil || d.IsDir() || filepath.Ext(path) != ".go" {
+				return err
+			}
+			rel, err := filepath.Rel(outputDir, path)
+			paths = append(paths, filepath.ToSlash(rel))
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s directory: %w", root, err)
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,159 @@
+package gen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteFiles(t *testing.T) {
+	header := GeneratedHeader + ". DO NOT EDIT\n"
+	tests := []struct {
+		name     string
+		existing map[string]string // files in the output directory before writing
+		files    []GeneratedFile
+		expected map[string]string // files in the output directory after writing, other than the manifest
+		manifest []string
+	}{
+		{
+			name: "new output",
+			files: []GeneratedFile{
+				{Path: "client.go", Content: []byte(header + "package authz\n")},
+				{Path: "permissions/user/user.go", Content: []byte(header + "package user\n")},
+			},
+			expected: map[string]string{
+				"client.go":                header + "package authz\n",
+				"permissions/user/user.go": header + "package user\n",
+			},
+			manifest: []string{"client.go", "permissions/user/user.go"},
+		},
+		{
+			name: "removes files not generated anymore",
+			existing: map[string]string{
+				ManifestFileName:               "client.go\npermissions/user/user.go\npermissions/folder/folder.go\n",
+				"client.go":                    header + "package authz\n",
+				"permissions/user/user.go":     header + "package user\n",
+				"permissions/folder/folder.go": header + "package folder\n",
+			},
+			files: []GeneratedFile{
+				{Path: "client.go", Content: []byte(header + "package authz\n")},
+				{Path: "permissions/user/user.go", Content: []byte(header + "package user\n")},
+			},
+			expected: map[string]string{
+				"client.go":                header + "package authz\n",
+				"permissions/user/user.go": header + "package user\n",
+			},
+			manifest: []string{"client.go", "permissions/user/user.go"},
+		},
+		{
+			name: "removes files of other emitters",
+			existing: map[string]string{
+				ManifestFileName: "client.go\nschema.md\nschema.dot\nnotes.md\n",
+				"client.go":      header + "package authz\n",
+				"schema.md":      "<!-- Code generated by spicegen. DO NOT EDIT. -->\n# Schema\n",
+				"schema.dot":     header + "digraph schema {}\n",
+				"notes.md":       "# Notes\n\nCode generated by spicegen is not edited by hand\n",
+			},
+			files: []GeneratedFile{
+				{Path: "client.go", Content: []byte(header + "package authz\n")},
+			},
+			expected: map[string]string{
+				"client.go": header + "package authz\n",
+				"notes.md":  "# Notes\n\nCode generated by spicegen is not edited by hand\n",
+			},
+			manifest: []string{"client.go"},
+		},
+		{
+			name: "keeps files not generated by spicegen",
+			existing: map[string]string{
+				ManifestFileName:               "client.go\npermissions/folder/folder.go\n../outside.go\n",
+				"client.go":                    header + "package authz\n",
+				"permissions/folder/folder.go": "package folder\n",
+				"permissions/folder/extra.go":  header + "package folder\n",
+				"helpers.go":                   "package authz\n",
+			},
+			files: []GeneratedFile{
+				{Path: "client.go", Content: []byte(header + "package authz\n\nvar _ = 1\n")},
+			},
+			expected: map[string]string{
+				"client.go":                    header + "package authz\n\nvar _ = 1\n",
+				"permissions/folder/folder.go": "package folder\n",
+				"permissions/folder/extra.go":  header + "package folder\n",
+				"helpers.go":                   "package authz\n",
+			},
+			manifest: []string{"client.go"},
+		},
+		{
+			name: "output without a manifest",
+			existing: map[string]string{
+				"client.go":                    header + "package authz\n",
+				"folder_enums.go":              header + "package authz\n",
+				"permissions/folder/folder.go": header + "package folder\n",
+				"other/other.go":               header + "package other\n",
+			},
+			files: []GeneratedFile{
+				{Path: "client.go", Content: []byte(header + "package authz\n")},
+				{Path: "permissions/user/user.go", Content: []byte(header + "package user\n")},
+			},
+			expected: map[string]string{
+				"client.go":                header + "package authz\n",
+				"permissions/user/user.go": header + "package user\n",
+				"other/other.go":           header + "package other\n",
+			},
+			manifest: []string{"client.go", "permissions/user/user.go"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "authz")
+			for path, content := range tc.existing {
+				assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, path)), 0755))
+				assert.NoError(t, os.WriteFile(filepath.Join(dir, path), []byte(content), 0644))
+			}
+			assert.NoError(t, WriteFiles(dir, tc.files))
+
+			actual := map[string]string{}
+			err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+				if err != nil || d.Name() == ManifestFileName {
+					return err
+				}
+				if d.IsDir() {
+					// no temporary directory or empty directory of a removed file is left behind
+					entries, err := os.ReadDir(path)
+					assert.NotEmpty(t, entries, path)
+					assert.NotContains(t, d.Name(), ".spicegen-")
+					return err
+				}
+				content, err := os.ReadFile(path)
+				rel, _ := filepath.Rel(dir, path)
+				actual[filepath.ToSlash(rel)] = string(content)
+				return err
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+
+			manifest, err := readManifest(dir)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.manifest, manifest)
+			// the output is up to date after writing
+			diffs, err := Diff(dir, tc.files)
+			assert.NoError(t, err)
+			assert.Empty(t, diffs)
+		})
+	}
+}
+
+func TestWriteFilesUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	files := []GeneratedFile{{Path: "client.go", Content: []byte(GeneratedHeader + ". DO NOT EDIT\npackage authz\n")}}
+	assert.NoError(t, WriteFiles(dir, files))
+	before, err := os.Stat(filepath.Join(dir, "client.go"))
+	assert.NoError(t, err)
+	assert.NoError(t, WriteFiles(dir, files))
+	after, err := os.Stat(filepath.Join(dir, "client.go"))
+	assert.NoError(t, err)
+	// the file is not replaced, so tools watching the output don't rebuild
+	assert.True(t, os.SameFile(before, after))
+}